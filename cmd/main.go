@@ -2,24 +2,57 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 
 	"github.com/Vovarama1992/chatra-ai-bridge/internal/ai"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/cases"
 	"github.com/Vovarama1992/chatra-ai-bridge/internal/chatra"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/dashboard"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/httpx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/jobs"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/logx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/metrics"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/outbox"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/pipeline"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tracing"
 )
 
 func main() {
 	_ = godotenv.Load()
 
+	// --- logs ---
+	// JSON в stdout, email/телефоны/токены маскируются; log.Printf библиотек — туда же
+	logCfg, err := logx.ConfigFromEnv()
+	if err != nil {
+		fatal("log config error", "err", err)
+	}
+	logx.Setup(logCfg)
+
+	// --- tracing ---
+	// вебхук → очередь → пайплайн → outbox → Chatra одной трассой; none — выключено
+	tracingCfg, err := tracing.ConfigFromEnv()
+	if err != nil {
+		fatal("tracing config error", "err", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingCfg)
+	if err != nil {
+		fatal("tracing setup error", "err", err)
+	}
+	slog.Info("tracing", "exporter", tracingCfg.Exporter, "sample_ratio", tracingCfg.SampleRatio)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -27,38 +60,195 @@ func main() {
 
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
-		log.Fatal("DATABASE_URL is not set")
+		fatal("DATABASE_URL is not set")
 	}
 
 	// --- DB ---
-	db, err := sql.Open("postgres", dsn)
+	// каждый запрос — в гистограмму bridge_db_query_duration_seconds
+	db, err := metrics.OpenDB(dsn)
 	if err != nil {
-		log.Fatalf("db open error: %v", err)
+		fatal("db open error", "err", err)
 	}
 	defer db.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := db.PingContext(ctx); err != nil {
-		log.Fatalf("db ping error: %v", err)
+		fatal("db ping error", "err", err)
 	}
 
 	// --- Router ---
 	r := chi.NewRouter()
+	// id запроса — во всех логах запроса, для вебхука — и дальше по очереди
+	r.Use(logx.Middleware)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-Webhook-Secret"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-Webhook-Secret", "X-Request-Id"},
+		ExposedHeaders: []string{"X-Request-Id"},
 	}))
 
-	chatraRepo := chatra.NewRepo(db)
-	aiClient := ai.NewOpenAIClient()
-	chatraOutbound := chatra.NewChatraOutbound()
+	// --- tenants ---
+	if os.Getenv("CHATRA_API_TOKEN") == "" {
+		slog.Warn("CHATRA_API_TOKEN is not set, default tenant keeps stored secret key")
+	}
+	if os.Getenv("CHATRA_PUBLIC_KEY") == "" {
+		slog.Warn("CHATRA_PUBLIC_KEY is not set, default tenant keeps stored public key")
+	}
+	tenantRepo := tenant.NewRepo(db)
+	if err := tenant.EnsureDefault(ctx, tenantRepo, tenant.Tenant{
+		Name:            os.Getenv("TENANT_DEFAULT_NAME"),
+		ChatraPublicKey: strings.TrimSpace(os.Getenv("CHATRA_PUBLIC_KEY")),
+		ChatraSecretKey: strings.TrimSpace(os.Getenv("CHATRA_API_TOKEN")),
+		WebhookSecrets:  splitList(os.Getenv("WEBHOOK_SECRET")),
+	}); err != nil {
+		// молча поднятый тенант без ключей — каждый вызов Chatra упадёт на авторизации
+		if errors.Is(err, tenant.ErrNoCredentials) {
+			fatal("default tenant has no chatra keys, set CHATRA_PUBLIC_KEY and CHATRA_API_TOKEN", "err", err)
+		}
+		// вебхуки с общим секретом не определить ни одному тенанту
+		if errors.Is(err, tenant.ErrSecretInUse) {
+			fatal("WEBHOOK_SECRET is already used by another tenant", "err", err)
+		}
+		slog.Error("default tenant error", "err", err)
+	}
+	tenants := tenant.NewRegistry(tenantRepo, 30*time.Second)
+
+	aiRegistry, err := ai.RegistryFromEnv()
+	if err != nil {
+		fatal("ai config error", "err", err)
+	}
+	slog.Info("ai stages", "stages", strings.Join(aiRegistry.Stages(), ", "))
+	tenantHandler := tenant.NewHandler(tenantRepo, tenants, aiRegistry)
+
+	// --- cases ---
+	casesRepo := cases.NewRepo(db)
+	if err := cases.ImportIfEmpty(ctx, casesRepo, tenant.DefaultID, chatra.NotVPNDomainPrompt); err != nil {
+		slog.Error("cases import error", "err", err)
+	}
+	casesLoader := cases.NewLoader(casesRepo)
+
+	tenantList, err := tenantRepo.List(ctx)
+	if err != nil {
+		slog.Error("tenants load error", "err", err)
+	}
+	for _, t := range tenantList {
+		if len(t.WebhookSecrets) == 0 {
+			slog.Warn("tenant has no webhook secrets, its webhooks will be rejected", "tenant", t.ID)
+		}
+		if err := ai.ValidateStages(t.Models); err != nil {
+			fatal("ai options validation error", "tenant", t.ID, "err", err)
+		}
+		// "provider:model" с ненастроенным провайдером ушёл бы провайдеру этапа как имя модели
+		if err := aiRegistry.CheckModels(t.Models); err != nil {
+			fatal("ai models validation error", "tenant", t.ID, "err", err)
+		}
+		if err := casesLoader.Validate(ctx, t.ID); err != nil {
+			var graphErr *cases.GraphError
+			if errors.As(err, &graphErr) {
+				fatal("cases validation error", "tenant", t.ID, "err", err)
+			}
+			slog.Error("cases load error", "tenant", t.ID, "err", err)
+		}
+	}
+	casesHandler := cases.NewHandler(cases.NewService(casesRepo))
+
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		slog.Warn("ADMIN_TOKEN is not set, admin API is disabled")
+	}
 
-	chatraService := chatra.NewService(chatraRepo, aiClient, chatraOutbound)
-	chatraHandler := chatra.NewHandler(chatraService)
+	outboxStore := outbox.NewStore(db)
+	chatraRepo := chatra.NewRepo(db, outboxStore)
+	runsRepo := pipeline.NewRepo(db)
+	// ответы этапов — JSON по схеме: проверка и одна попытка починки
+	var aiClient ai.AI = ai.NewStructured(aiRegistry)
+	// одинаковый вход этапа — ответ из кеша, без платного вызова
+	if ttl := envInt("AI_CACHE_TTL_SECONDS", 0); ttl > 0 {
+		stages := splitList(envOr("AI_CACHE_STAGES", ai.StageFactSelector+","+ai.StageFactValidator))
+		for _, st := range stages {
+			if !ai.IsStage(st) {
+				fatal("AI_CACHE_STAGES: unknown stage", "stage", st)
+			}
+		}
+		aiClient = ai.NewCache(aiClient, time.Duration(ttl)*time.Second, envInt("AI_CACHE_MAX_ENTRIES", 10000), stages, aiRegistry.Model)
+		slog.Info("ai cache", "stages", strings.Join(stages, ","), "ttl_seconds", ttl)
+	}
+	chatraConfig := chatra.ChatraConfigFromEnv()
+	slog.Info("chatra api", "base_url", envOr("CHATRA_API_BASE_URL", chatra.DefaultChatraBaseURL))
+	chatraOutbounds := chatra.NewChatraOutbounds(chatraConfig)
 
-	chatra.RegisterRoutes(r, chatraHandler)
+	publicURL := os.Getenv("PUBLIC_BASE_URL")
+	if publicURL == "" {
+		slog.Warn("PUBLIC_BASE_URL is not set, approval links in notes will be relative")
+	}
+	approvals := chatra.NewApprovals(
+		chatra.NewDrafts(db),
+		chatraRepo,
+		time.Duration(envInt("APPROVAL_TTL_MINUTES", 60))*time.Minute,
+		publicURL,
+	)
+
+	// бюджет на модели: превышен — ответы клиентам только заметками + алерт
+	budget := pipeline.NewBudget(
+		runsRepo,
+		envFloat("AI_BUDGET_DAILY_USD", 0),
+		envFloat("AI_BUDGET_MONTHLY_USD", 0),
+		pipeline.WebhookAlert(os.Getenv("ALERT_WEBHOOK_URL")),
+	)
+	// модель без цены стоит 0 — лимит по ней никогда не сработает
+	if unpriced := aiRegistry.Unpriced(); budget != nil && len(unpriced) > 0 {
+		fatal("AI budget is set but routed models have no price, add them to AI_PRICES", "stages", strings.Join(unpriced, ", "))
+	}
+
+	chatraService := chatra.NewService(
+		chatraRepo,
+		aiClient,
+		casesLoader,
+		tenants,
+		runsRepo,
+		budget,
+		approvals,
+	)
+
+	// --- jobs ---
+	queue := jobs.NewQueue(db)
+	pool := jobs.NewPool(queue, chatra.NewJobHandler(chatraService), envInt("JOB_WORKERS", 4))
+	metrics.RegisterQueue("jobs", func(ctx context.Context) (map[string]int, float64, error) {
+		d, err := queue.Depth(ctx)
+		byStatus := make(map[string]int, len(d.ByStatus))
+		for st, n := range d.ByStatus {
+			byStatus[string(st)] = n
+		}
+		return byStatus, d.OldestPending, err
+	})
+
+	// ответы и заметки пишутся в outbox вместе с сообщением, отправляет диспетчер
+	dispatcher := outbox.NewDispatcher(
+		outboxStore,
+		chatra.NewOutboxSender(chatraOutbounds, tenants),
+		envInt("OUTBOX_WORKERS", 2),
+	)
+
+	// окно тишины — в очереди: фрагменты чата копятся до тишины клиента и
+	// отвечаются одной пачкой; подтверждаются только после ответа
+	chatraHandler := chatra.NewHandler(queue, tenants, time.Duration(envInt("DEBOUNCE_SECONDS", 0))*time.Second)
+
+	chatra.RegisterRoutes(r, chatraHandler, chatra.NewApprovalHandler(approvals), chatra.AdminHandlers{
+		Cases:   casesHandler,
+		Tenants: tenantHandler,
+		Queue:   jobs.DepthHandler(queue),
+		Runs:    pipeline.NewHandler(runsRepo, budget),
+		UI:      dashboard.NewHandler(dashboard.NewRepo(db), runsRepo),
+	}, adminToken)
+
+	// --- metrics ---
+	// METRICS_TOKEN не задан — /metrics открыт (закрывается сетью, как /ping)
+	if token := os.Getenv("METRICS_TOKEN"); token != "" {
+		r.With(httpx.AdminOnly(token)).Handle("/metrics", metrics.Handler())
+	} else {
+		r.Handle("/metrics", metrics.Handler())
+	}
 
 	// --- health ---
 	r.Get("/ping", func(w http.ResponseWriter, _ *http.Request) {
@@ -66,8 +256,75 @@ func main() {
 		w.Write([]byte("pong"))
 	})
 
-	log.Printf("listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatalf("server error: %v", err)
+	runCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pool.Start(runCtx)
+	dispatcher.Start(runCtx)
+
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		slog.Info("listening", "port", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("server error", "err", err)
+		}
+	}()
+
+	<-runCtx.Done()
+	slog.Info("shutting down")
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server shutdown error", "err", err)
+	}
+
+	// недоделанные задачи останутся в очереди и поднимутся при старте
+	pool.Stop()
+	dispatcher.Stop()
+
+	// дописать буфер спанов до выхода
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("tracing shutdown error", "err", err)
+	}
+}
+
+// fatal — ошибка старта: в лог уровнем error и выход
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// splitList — "a, b,c" → [a b c]
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+func envFloat(key string, def float64) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv(key)), 64)
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+func envOr(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
 	}
+	return def
 }
@@ -0,0 +1,229 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAnthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion        = "2023-06-01"
+	anthropicMaxTokens      = 4096
+)
+
+// AnthropicConfig — Messages API Anthropic
+type AnthropicConfig struct {
+	APIKey  string
+	BaseURL string // "" — api.anthropic.com
+	Model   string // модель по умолчанию, если этап не задал свою
+	// Transport — nil: http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+type AnthropicClient struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func NewAnthropicClient(cfg AnthropicConfig) (*AnthropicClient, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("anthropic: api key is not set")
+	}
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultAnthropicBaseURL
+	}
+
+	return &AnthropicClient{
+		baseURL: baseURL,
+		apiKey:  cfg.APIKey,
+		model:   cfg.Model,
+		client:  &http.Client{Timeout: 2 * time.Minute, Transport: cfg.Transport},
+	}, nil
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature *float32           `json:"temperature,omitempty"`
+	Thinking    *anthropicThinking `json:"thinking,omitempty"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice  *anthropicChoice   `json:"tool_choice,omitempty"`
+}
+
+// structured output у Anthropic — вызов единственного инструмента со схемой
+type anthropicTool struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	InputSchema *Schema `json:"input_schema"`
+}
+
+type anthropicChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// thinkingBudget — reasoning_effort в бюджет токенов на рассуждение
+var thinkingBudget = map[string]int{
+	"low":    1024,
+	"medium": 4096,
+	"high":   16384,
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Input json.RawMessage `json:"input"` // tool_use
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (c *AnthropicClient) DefaultModel() string {
+	return c.model
+}
+
+func (c *AnthropicClient) GetReply(
+	ctx context.Context,
+	opts Options,
+	systemPrompt string,
+	inputJSON string,
+) (Reply, error) {
+
+	started := time.Now()
+	reply, err := c.complete(ctx, opts, systemPrompt, inputJSON)
+	if reply.Model != "" {
+		observeCall("anthropic", reply.Model, started, reply.Usage, err)
+	}
+	return reply, err
+}
+
+func (c *AnthropicClient) complete(
+	ctx context.Context,
+	opts Options,
+	systemPrompt string,
+	inputJSON string,
+) (Reply, error) {
+
+	model := opts.Model
+	if model == "" {
+		model = c.model
+	}
+	if model == "" {
+		return Reply{}, errors.New("anthropic: model is not set")
+	}
+
+	req := anthropicRequest{
+		Model:       model,
+		MaxTokens:   opts.MaxTokens,
+		Temperature: opts.Temperature,
+		System:      systemPrompt,
+		Messages:    []anthropicMessage{{Role: "user", Content: inputJSON}},
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = anthropicMaxTokens
+	}
+	// рассуждение идёт в счёт max_tokens и несовместимо с temperature
+	if budget, ok := thinkingBudget[opts.ReasoningEffort]; ok {
+		req.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: budget}
+		req.MaxTokens += budget
+		req.Temperature = nil
+	}
+	// принудительный tool_choice с рассуждением несовместим — тогда схему
+	// держит только промпт, а ответ проверит Structured
+	if opts.Schema != nil && req.Thinking == nil {
+		name := schemaName(opts.Stage)
+		req.Tools = []anthropicTool{{Name: name, Description: "Return the answer", InputSchema: opts.Schema}}
+		req.ToolChoice = &anthropicChoice{Type: "tool", Name: name}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Reply{Model: model}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return Reply{Model: model}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", c.apiKey)
+	httpReq.Header.Set("Anthropic-Version", anthropicVersion)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		slog.ErrorContext(ctx, "ai request failed", "provider", "anthropic", "model", model, "stage", opts.Stage, "err", err)
+		return Reply{Model: model}, err
+	}
+	defer resp.Body.Close()
+
+	var out anthropicResponse
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err := json.Unmarshal(raw, &out); err != nil && resp.StatusCode < 300 {
+		return Reply{Model: model}, err
+	}
+	if resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(raw))
+		if out.Error != nil {
+			msg = out.Error.Type + ": " + out.Error.Message
+		}
+		err := fmt.Errorf("anthropic: %s: %s", resp.Status, msg)
+		slog.ErrorContext(ctx, "ai request failed", "provider", "anthropic", "model", model, "stage", opts.Stage, "err", err)
+		return Reply{Model: model}, err
+	}
+
+	reply := Reply{
+		Model: out.Model,
+		Usage: Usage{
+			PromptTokens:     out.Usage.InputTokens,
+			CompletionTokens: out.Usage.OutputTokens,
+			TotalTokens:      out.Usage.InputTokens + out.Usage.OutputTokens,
+		},
+	}
+	if reply.Model == "" {
+		reply.Model = model
+	}
+
+	var text strings.Builder
+	for _, block := range out.Content {
+		switch block.Type {
+		case "tool_use":
+			reply.Text = string(block.Input)
+			return reply, nil
+		case "text":
+			text.WriteString(block.Text)
+		}
+	}
+	reply.Text = text.String()
+	return reply, nil
+}
@@ -0,0 +1,160 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/metrics"
+)
+
+// Cache — одинаковый вход этапа не оплачивается дважды. Ключ — этап, итоговая
+// модель, хеш промпта и нормализованный вход; кешируются только успешные ответы.
+// Ответ из кеша — Cached, без токенов и стоимости.
+type Cache struct {
+	next   AI
+	store  *memoryCache
+	ttl    time.Duration
+	stages map[string]bool
+	model  func(Options) string // модель, которую выберет Registry
+}
+
+// NewCache — stages: какие этапы кешировать; model — Registry.Model
+func NewCache(next AI, ttl time.Duration, maxEntries int, stages []string, model func(Options) string) *Cache {
+	set := map[string]bool{}
+	for _, s := range stages {
+		set[s] = true
+	}
+	return &Cache{
+		next:   next,
+		store:  newMemoryCache(maxEntries),
+		ttl:    ttl,
+		stages: set,
+		model:  model,
+	}
+}
+
+func (c *Cache) GetReply(
+	ctx context.Context,
+	opts Options,
+	systemPrompt string,
+	inputJSON string,
+) (Reply, error) {
+
+	if !c.stages[opts.Stage] {
+		return c.next.GetReply(ctx, opts, systemPrompt, inputJSON)
+	}
+
+	key, ok := cacheKey(opts.Stage, c.model(opts), systemPrompt, inputJSON)
+	if !ok {
+		return c.next.GetReply(ctx, opts, systemPrompt, inputJSON)
+	}
+
+	if reply, hit := c.store.get(key); hit {
+		metrics.AICache.WithLabelValues(opts.Stage, "hit").Inc()
+		trace.SpanFromContext(ctx).AddEvent("ai cache hit")
+		return reply, nil
+	}
+	metrics.AICache.WithLabelValues(opts.Stage, "miss").Inc()
+
+	reply, err := c.next.GetReply(ctx, opts, systemPrompt, inputJSON)
+	if err == nil {
+		cached := reply
+		cached.Cached = true
+		cached.Usage = Usage{}
+		cached.CostUSD = 0
+		c.store.put(key, cached, c.ttl)
+	}
+	return reply, err
+}
+
+// cacheKey — sha256 от этапа, модели, промпта и входа с отсортированными ключами
+func cacheKey(stage, model, systemPrompt, inputJSON string) (string, bool) {
+	// числа как есть: через float64 большие id разных клиентов дали бы один ключ
+	dec := json.NewDecoder(bytes.NewReader([]byte(inputJSON)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return "", false
+	}
+	// encoding/json пишет ключи map по порядку — вход нормализован
+	var norm bytes.Buffer
+	enc := json.NewEncoder(&norm)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", false
+	}
+
+	prompt := sha256.Sum256([]byte(systemPrompt))
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(stage), []byte(model), prompt[:], norm.Bytes()} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+type cacheEntry struct {
+	reply   Reply
+	expires time.Time
+}
+
+type memoryCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]cacheEntry
+}
+
+func newMemoryCache(max int) *memoryCache {
+	if max <= 0 {
+		max = 10000
+	}
+	return &memoryCache{max: max, entries: map[string]cacheEntry{}}
+}
+
+func (m *memoryCache) get(key string) (Reply, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return Reply{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(m.entries, key)
+		return Reply{}, false
+	}
+	return e.reply, true
+}
+
+func (m *memoryCache) put(key string, reply Reply, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.entries) >= m.max {
+		m.evict()
+	}
+	m.entries[key] = cacheEntry{reply: reply, expires: time.Now().Add(ttl)}
+}
+
+// evict — сначала просроченные; не помогло — любая запись (порядок map случайный)
+func (m *memoryCache) evict() {
+	now := time.Now()
+	for k, e := range m.entries {
+		if now.After(e.expires) {
+			delete(m.entries, k)
+		}
+	}
+	for k := range m.entries {
+		if len(m.entries) < m.max {
+			return
+		}
+		delete(m.entries, k)
+	}
+}
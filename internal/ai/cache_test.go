@@ -0,0 +1,118 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	base, ok := cacheKey(StageFactSelector, "openai:gpt-5.2", "prompt", `{"a":1,"b":[1,2]}`)
+	if !ok {
+		t.Fatal("valid input not cacheable")
+	}
+
+	tests := []struct {
+		name                       string
+		stage, model, prompt, json string
+		wantSame, wantOK           bool
+	}{
+		{"same input", StageFactSelector, "openai:gpt-5.2", "prompt", `{"a":1,"b":[1,2]}`, true, true},
+		{"key order and spaces", StageFactSelector, "openai:gpt-5.2", "prompt", "{ \"b\": [1, 2],\n \"a\": 1 }", true, true},
+		{"other stage", StageFactValidator, "openai:gpt-5.2", "prompt", `{"a":1,"b":[1,2]}`, false, true},
+		{"other model", StageFactSelector, "openai:gpt-4o-mini", "prompt", `{"a":1,"b":[1,2]}`, false, true},
+		{"other prompt", StageFactSelector, "openai:gpt-5.2", "prompt v2", `{"a":1,"b":[1,2]}`, false, true},
+		{"other value", StageFactSelector, "openai:gpt-5.2", "prompt", `{"a":2,"b":[1,2]}`, false, true},
+		{"array order matters", StageFactSelector, "openai:gpt-5.2", "prompt", `{"a":1,"b":[2,1]}`, false, true},
+		{"invalid json", StageFactSelector, "openai:gpt-5.2", "prompt", `{"a":`, false, false},
+		{"trailing data", StageFactSelector, "openai:gpt-5.2", "prompt", `{"a":1} {}`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := cacheKey(tt.stage, tt.model, tt.prompt, tt.json)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && (key == base) != tt.wantSame {
+				t.Errorf("same key = %v, want %v", key == base, tt.wantSame)
+			}
+		})
+	}
+
+	// большие id не должны схлопываться через float64
+	a, _ := cacheKey(StageFactSelector, "m", "p", `{"client_id":12345678901234567}`)
+	b, _ := cacheKey(StageFactSelector, "m", "p", `{"client_id":12345678901234568}`)
+	if a == b {
+		t.Error("different large ids share a cache key")
+	}
+}
+
+// countingReplies — считает вызовы, может ответить ошибкой
+type countingReplies struct {
+	calls int
+	err   error
+}
+
+func (a *countingReplies) GetReply(context.Context, Options, string, string) (Reply, error) {
+	a.calls++
+	return Reply{Text: "ok", Usage: Usage{TotalTokens: 10}, CostUSD: 0.01}, a.err
+}
+
+func TestCacheGetReply(t *testing.T) {
+	model := func(Options) string { return "openai:gpt-5.2" }
+	ctx := context.Background()
+
+	t.Run("hit is free and marked cached", func(t *testing.T) {
+		next := &countingReplies{}
+		c := NewCache(next, time.Minute, 10, []string{StageFactSelector}, model)
+		opts := Options{Stage: StageFactSelector}
+
+		first, _ := c.GetReply(ctx, opts, "p", `{"q":1}`)
+		second, _ := c.GetReply(ctx, opts, "p", `{"q":1}`)
+		if next.calls != 1 {
+			t.Fatalf("calls = %d, want 1", next.calls)
+		}
+		if first.Cached || first.CostUSD == 0 {
+			t.Errorf("first reply = %+v, want paid and not cached", first)
+		}
+		if !second.Cached || second.CostUSD != 0 || second.Usage.TotalTokens != 0 {
+			t.Errorf("second reply = %+v, want cached without cost", second)
+		}
+	})
+
+	t.Run("stage not cached", func(t *testing.T) {
+		next := &countingReplies{}
+		c := NewCache(next, time.Minute, 10, []string{StageFactSelector}, model)
+		for i := 0; i < 2; i++ {
+			_, _ = c.GetReply(ctx, Options{Stage: StageAnswerBuilder}, "p", `{"q":1}`)
+		}
+		if next.calls != 2 {
+			t.Errorf("calls = %d, want 2", next.calls)
+		}
+	})
+
+	t.Run("errors not cached", func(t *testing.T) {
+		next := &countingReplies{err: errors.New("timeout")}
+		c := NewCache(next, time.Minute, 10, []string{StageFactSelector}, model)
+		for i := 0; i < 2; i++ {
+			_, _ = c.GetReply(ctx, Options{Stage: StageFactSelector}, "p", `{"q":1}`)
+		}
+		if next.calls != 2 {
+			t.Errorf("calls = %d, want 2", next.calls)
+		}
+	})
+
+	t.Run("expired entry", func(t *testing.T) {
+		next := &countingReplies{}
+		c := NewCache(next, time.Nanosecond, 10, []string{StageFactSelector}, model)
+		for i := 0; i < 2; i++ {
+			_, _ = c.GetReply(ctx, Options{Stage: StageFactSelector}, "p", `{"q":1}`)
+			time.Sleep(time.Millisecond)
+		}
+		if next.calls != 2 {
+			t.Errorf("calls = %d, want 2", next.calls)
+		}
+	})
+}
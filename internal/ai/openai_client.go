@@ -2,35 +2,70 @@ package ai
 
 import (
 	"context"
-	"log"
-	"os"
+	"errors"
+	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/metrics"
 	openai "github.com/sashabaranov/go-openai"
 )
 
+// OpenAIConfig — OpenAI или любой совместимый API (vLLM, Ollama, ...)
+type OpenAIConfig struct {
+	Name    string // имя провайдера в логах
+	APIKey  string
+	BaseURL string // "" — api.openai.com
+	Model   string // модель по умолчанию
+}
+
 type OpenAIClient struct {
 	client *openai.Client
+	name   string
+	model  string
+	// compat — не OpenAI: max_completion_tokens там понимают не все
+	compat bool
 }
 
-func NewOpenAIClient() *OpenAIClient {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY not set")
+func NewOpenAIClient(cfg OpenAIConfig) (*OpenAIClient, error) {
+	if cfg.Name == "" {
+		cfg.Name = "openai"
+	}
+	if cfg.APIKey == "" && cfg.BaseURL == "" {
+		return nil, errors.New("openai: api key is not set")
 	}
 
-	return &OpenAIClient{
-		client: openai.NewClient(apiKey),
+	conf := openai.DefaultConfig(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		conf.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
 	}
+
+	return &OpenAIClient{
+		client: openai.NewClientWithConfig(conf),
+		name:   cfg.Name,
+		model:  cfg.Model,
+		compat: cfg.BaseURL != "",
+	}, nil
+}
+
+func (c *OpenAIClient) DefaultModel() string {
+	return c.model
 }
 
 func (c *OpenAIClient) GetReply(
 	ctx context.Context,
+	opts Options,
 	systemPrompt string,
 	inputJSON string,
-) (string, error) {
+) (Reply, error) {
 
-	model := c.pickModel(systemPrompt)
+	model := opts.Model
+	if model == "" {
+		model = c.model
+	}
+	if model == "" {
+		return Reply{}, errors.New(c.name + ": model is not set")
+	}
 
 	msgs := []openai.ChatCompletionMessage{
 		{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
@@ -38,21 +73,76 @@ func (c *OpenAIClient) GetReply(
 	}
 
 	req := openai.ChatCompletionRequest{
-		Model:    model,
-		Messages: msgs,
+		Model:           model,
+		Messages:        msgs,
+		ReasoningEffort: opts.ReasoningEffort,
+	}
+	// temperature 0 библиотека не передаёт (omitempty) — провайдер возьмёт свою
+	if opts.Temperature != nil {
+		req.Temperature = *opts.Temperature
+	}
+	if c.compat {
+		req.MaxTokens = opts.MaxTokens
+	} else {
+		req.MaxCompletionTokens = opts.MaxTokens
+	}
+	if opts.Schema != nil {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   schemaName(opts.Stage),
+				Schema: opts.Schema,
+				// совместимые серверы strict понимают не все — там проверит Structured
+				Strict: !c.compat,
+			},
+		}
 	}
 
+	started := time.Now()
 	resp, err := c.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		log.Printf("[AI ERROR][%s] %v\n", model, err)
-		return "", err
+		slog.ErrorContext(ctx, "ai request failed", "provider", c.name, "model", model, "stage", opts.Stage, "err", err)
+		observeCall(c.name, model, started, Usage{}, err)
+		return Reply{Model: model}, err
+	}
+
+	reply := Reply{
+		Model: resp.Model,
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}
+	if reply.Model == "" {
+		reply.Model = model
 	}
+	observeCall(c.name, reply.Model, started, reply.Usage, nil)
 
 	if len(resp.Choices) == 0 {
-		return "", nil
+		return reply, nil
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	reply.Text = resp.Choices[0].Message.Content
+	return reply, nil
+}
+
+// observeCall — метрики одного запроса к провайдеру: исход, время, токены
+func observeCall(provider, model string, started time.Time, usage Usage, err error) {
+	result := "ok"
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		result = "timeout"
+	case err != nil:
+		result = "error"
+	}
+	metrics.AIRequests.WithLabelValues(provider, model, result).Inc()
+	metrics.AIDuration.WithLabelValues(provider).Observe(time.Since(started).Seconds())
+	if err != nil {
+		return
+	}
+	metrics.AITokens.WithLabelValues(provider, model, "prompt").Add(float64(usage.PromptTokens))
+	metrics.AITokens.WithLabelValues(provider, model, "completion").Add(float64(usage.CompletionTokens))
 }
 
 func short(s string) string {
@@ -61,23 +151,3 @@ func short(s string) string {
 	}
 	return s
 }
-
-func (c *OpenAIClient) pickModel(systemPrompt string) string {
-
-	switch {
-	case strings.Contains(systemPrompt, "FACT SELECTOR"):
-		return "gpt-4o-mini"
-
-	case strings.Contains(systemPrompt, "FACT VALIDATOR"):
-		return "gpt-5.2"
-
-	case strings.Contains(systemPrompt, "ANSWER BUILDER"):
-		return "gpt-5.2"
-
-	case strings.Contains(systemPrompt, "ANSWER VALIDATOR"):
-		return "gpt-5.2"
-
-	default:
-		return "gpt-4o-mini"
-	}
-}
@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AllStages — этапы пайплайна, которые можно настраивать
+var AllStages = []string{
+	StageFactSelector,
+	StageFactValidator,
+	StageAnswerBuilder,
+	StageAnswerValidator,
+}
+
+func IsStage(s string) bool {
+	for _, st := range AllStages {
+		if st == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Options — параметры одного вызова модели. Нулевое поле — «не задано»:
+// берётся из настроек этапа, затем из настроек провайдера.
+type Options struct {
+	Stage string `json:"-"`
+	// Model — "gpt-4o-mini" или "provider:model", тогда меняется и провайдер
+	Model       string   `json:"model,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	// TimeoutSeconds — на весь вызов провайдера
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// ReasoningEffort — low / medium / high для моделей с рассуждением
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	// Schema — ответ строго JSON по схеме этапа; задаётся кодом, не конфигом
+	Schema *Schema `json:"-"`
+}
+
+// UnmarshalJSON — старый формат настроек тенанта: stage → "имя модели"
+func (o *Options) UnmarshalJSON(b []byte) error {
+	var model string
+	if err := json.Unmarshal(b, &model); err == nil {
+		*o = Options{Model: model}
+		return nil
+	}
+	type plain Options
+	return json.Unmarshal(b, (*plain)(o))
+}
+
+func (o Options) Timeout() time.Duration {
+	return time.Duration(o.TimeoutSeconds) * time.Second
+}
+
+// Merge — поля override поверх o
+func (o Options) Merge(override Options) Options {
+	if override.Stage != "" {
+		o.Stage = override.Stage
+	}
+	if override.Model != "" {
+		o.Model = override.Model
+	}
+	if override.Temperature != nil {
+		o.Temperature = override.Temperature
+	}
+	if override.MaxTokens != 0 {
+		o.MaxTokens = override.MaxTokens
+	}
+	if override.TimeoutSeconds != 0 {
+		o.TimeoutSeconds = override.TimeoutSeconds
+	}
+	if override.ReasoningEffort != "" {
+		o.ReasoningEffort = override.ReasoningEffort
+	}
+	if override.Schema != nil {
+		o.Schema = override.Schema
+	}
+	return o
+}
+
+func (o Options) Validate() error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return fmt.Errorf("temperature %v out of range 0..2", *o.Temperature)
+	}
+	if o.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must be positive, got %d", o.MaxTokens)
+	}
+	if o.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeout_seconds must be positive, got %d", o.TimeoutSeconds)
+	}
+	switch o.ReasoningEffort {
+	case "", "low", "medium", "high":
+	default:
+		return fmt.Errorf("unknown reasoning_effort %q", o.ReasoningEffort)
+	}
+	return nil
+}
+
+// ValidateStages — настройки по этапам (AI_STAGE_OPTIONS, модели тенанта)
+func ValidateStages(stages map[string]Options) error {
+	for stage, o := range stages {
+		if !IsStage(stage) {
+			return fmt.Errorf("unknown stage %q", stage)
+		}
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("stage %s: %w", stage, err)
+		}
+	}
+	return nil
+}
@@ -6,9 +6,36 @@ import "context"
 type AI interface {
 	GetReply(
 		ctx context.Context,
+		opts Options,
 		systemPrompt string,
 		inputJSON string,
-	) (string, error)
+	) (Reply, error)
+}
+
+// Provider — один бэкенд в Registry; opts.Model уже выбран реестром
+type Provider interface {
+	AI
+	// DefaultModel — модель, если ни этап, ни тенант её не задали; "" — нет
+	DefaultModel() string
+}
+
+// Reply — ответ модели вместе с тем, кто и сколько за него посчитал
+type Reply struct {
+	Text  string
+	Model string // фактическая модель из ответа провайдера
+	// Provider — кто отвечал (openai, anthropic, ollama, ...); заполняет Registry
+	Provider string
+	// CostUSD — по таблице цен Registry; 0 — цена модели неизвестна
+	CostUSD float64
+	// Cached — ответ из Cache, провайдер не вызывался
+	Cached bool
+	Usage  Usage
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 // Message — универсальный формат диалога для AI
@@ -16,3 +43,11 @@ type Message struct {
 	Role string // "user" | "assistant" | "system"
 	Text string
 }
+
+// Этапы пайплайна — ключи для настроек тенанта
+const (
+	StageFactSelector    = "fact_selector"
+	StageFactValidator   = "fact_validator"
+	StageAnswerBuilder   = "answer_builder"
+	StageAnswerValidator = "answer_validator"
+)
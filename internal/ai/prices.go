@@ -0,0 +1,95 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Price — USD за 1M токенов
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// PriceTable — ключ "provider:model" или "model"; модель из ответа провайдера
+// часто с датой (gpt-4o-mini-2024-07-18) — тогда берётся самый длинный префикс
+type PriceTable map[string]Price
+
+// DefaultPrices — модели из маршрутов по умолчанию (.env.example), чтобы бюджет
+// считался без AI_PRICES; AI_PRICES дополняет и перекрывает их
+var DefaultPrices = PriceTable{
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.6},
+	"gpt-5.2":           {Input: 1.75, Output: 14},
+	"gpt-5.2-pro":       {Input: 21, Output: 168}, // иначе по префиксу посчитается как gpt-5.2
+	"claude-sonnet-4-5": {Input: 3, Output: 15},
+}
+
+var unpriced sync.Map // модели без цены — предупреждаем один раз
+
+// Cost — стоимость вызова; false — цены для модели нет
+func (t PriceTable) Cost(provider, model string, u Usage) (float64, bool) {
+	p, ok := t.lookup(provider, model)
+	if !ok {
+		if _, seen := unpriced.LoadOrStore(provider+":"+model, true); !seen && model != "" {
+			slog.Warn("ai: no price for model, cost is counted as 0", "provider", provider, "model", model)
+		}
+		return 0, false
+	}
+	return (float64(u.PromptTokens)*p.Input + float64(u.CompletionTokens)*p.Output) / 1e6, true
+}
+
+func (t PriceTable) lookup(provider, model string) (Price, bool) {
+	if p, ok := t[provider+":"+model]; ok {
+		return p, true
+	}
+	if p, ok := t[model]; ok {
+		return p, true
+	}
+
+	best, found := "", false
+	var price Price
+	for key, p := range t {
+		name := key
+		if prov, m, ok := strings.Cut(key, ":"); ok && prov == provider {
+			name = m
+		}
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best, price, found = name, p, true
+		}
+	}
+	return price, found
+}
+
+// Priced — есть ли цена для модели
+func (t PriceTable) Priced(provider, model string) bool {
+	_, ok := t.lookup(provider, model)
+	return ok
+}
+
+// PricesFromEnv — DefaultPrices и поверх них
+// AI_PRICES={"gpt-4o-mini":{"input":0.15,"output":0.6},"ollama:llama3.1:8b":{}}
+func PricesFromEnv() (PriceTable, error) {
+	t := PriceTable{}
+	for model, p := range DefaultPrices {
+		t[model] = p
+	}
+	v := strings.TrimSpace(os.Getenv("AI_PRICES"))
+	if v == "" {
+		return t, nil
+	}
+	custom := PriceTable{}
+	if err := json.Unmarshal([]byte(v), &custom); err != nil {
+		return nil, fmt.Errorf("ai: AI_PRICES: %w", err)
+	}
+	for model, p := range custom {
+		if p.Input < 0 || p.Output < 0 {
+			return nil, fmt.Errorf("ai: AI_PRICES: negative price for %s", model)
+		}
+		t[model] = p
+	}
+	return t, nil
+}
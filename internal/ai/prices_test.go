@@ -0,0 +1,115 @@
+package ai
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPriceTableLookup(t *testing.T) {
+	table := PriceTable{
+		"gpt-4o-mini":        {Input: 0.15, Output: 0.6},
+		"gpt-5.2":            {Input: 1.75, Output: 14},
+		"gpt-5.2-pro":        {Input: 21, Output: 168},
+		"openai:gpt-4o":      {Input: 2.5, Output: 10},
+		"ollama:llama3.1:8b": {},
+	}
+
+	tests := []struct {
+		name      string
+		provider  string
+		model     string
+		want      Price
+		wantFound bool
+	}{
+		{"exact model", "openai", "gpt-5.2", Price{1.75, 14}, true},
+		{"dated model by prefix", "openai", "gpt-4o-mini-2024-07-18", Price{0.15, 0.6}, true},
+		{"longest prefix wins", "openai", "gpt-5.2-pro-2025-12-11", Price{21, 168}, true},
+		{"provider key", "openai", "gpt-4o-2024-08-06", Price{2.5, 10}, true},
+		{"provider key of other provider", "azure", "gpt-4o", Price{}, false},
+		{"model with colon", "ollama", "llama3.1:8b", Price{}, true},
+		{"unknown", "anthropic", "claude-opus-4-1", Price{}, false},
+		{"empty model", "openai", "", Price{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := table.lookup(tt.provider, tt.model)
+			if found != tt.wantFound || got != tt.want {
+				t.Errorf("lookup = %+v %v, want %+v %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestPriceTableCost(t *testing.T) {
+	table := PriceTable{"gpt-5.2": {Input: 1.75, Output: 14}}
+
+	cost, ok := table.Cost("openai", "gpt-5.2", Usage{PromptTokens: 1_000_000, CompletionTokens: 500_000})
+	if !ok || cost != 8.75 {
+		t.Errorf("cost = %v %v, want 8.75 true", cost, ok)
+	}
+	if cost, ok := table.Cost("openai", "other", Usage{PromptTokens: 1000}); ok || cost != 0 {
+		t.Errorf("unpriced cost = %v %v, want 0 false", cost, ok)
+	}
+}
+
+func TestPricesFromEnv(t *testing.T) {
+	t.Setenv("AI_PRICES", `{"gpt-5.2":{"input":1,"output":2},"ollama:llama3.1:8b":{}}`)
+
+	table, err := PricesFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := table["gpt-5.2"]; got != (Price{1, 2}) {
+		t.Errorf("gpt-5.2 = %+v, want override from AI_PRICES", got)
+	}
+	if got := table["claude-sonnet-4-5"]; got != DefaultPrices["claude-sonnet-4-5"] {
+		t.Errorf("claude-sonnet-4-5 = %+v, want default price", got)
+	}
+	if !table.Priced("ollama", "llama3.1:8b") {
+		t.Error("free model from AI_PRICES must count as priced")
+	}
+
+	t.Setenv("AI_PRICES", `{"gpt-5.2":{"input":-1}}`)
+	if _, err := PricesFromEnv(); err == nil {
+		t.Error("want error for negative price")
+	}
+}
+
+// modelProvider — провайдер, у которого есть только модель по умолчанию
+type modelProvider string
+
+func (p modelProvider) DefaultModel() string { return string(p) }
+
+func (modelProvider) GetReply(context.Context, Options, string, string) (Reply, error) {
+	return Reply{}, nil
+}
+
+func TestRegistryUnpriced(t *testing.T) {
+	providers := map[string]Provider{"openai": modelProvider("gpt-4o-mini"), "ollama": modelProvider("llama3.1:8b")}
+	stages := map[string]Route{
+		StageAnswerBuilder: {Provider: "openai", Model: "gpt-5.2"},
+		StageFactSelector:  {Provider: "ollama"},
+	}
+
+	tests := []struct {
+		name   string
+		prices PriceTable
+		want   []string
+	}{
+		{"default prices miss ollama", DefaultPrices, []string{StageFactSelector + "=ollama:llama3.1:8b"}},
+		{"everything priced", PriceTable{"gpt": {}, "ollama:llama3.1:8b": {}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRegistry(providers, Route{Provider: "openai"}, stages, nil, tt.prices)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Unpriced(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unpriced = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/metrics"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tracing"
+)
+
+// Route — куда идёт этап: провайдер и (необязательно) модель
+type Route struct {
+	Provider string
+	Model    string
+}
+
+// ParseRoute — "anthropic" или "anthropic:claude-sonnet-4-5";
+// модель может сама содержать ':' (ollama: "llama3.1:8b")
+func ParseRoute(s string) Route {
+	s = strings.TrimSpace(s)
+	provider, model, _ := strings.Cut(s, ":")
+	return Route{Provider: strings.TrimSpace(provider), Model: strings.TrimSpace(model)}
+}
+
+func (r Route) String() string {
+	if r.Model == "" {
+		return r.Provider
+	}
+	return r.Provider + ":" + r.Model
+}
+
+// Registry — несколько провайдеров за одним AI; провайдер выбирается по opts.Stage.
+// Параметры вызова: тенант (opts) поверх настроек этапа; модель тенанта
+// перекрывает модель этапа, а в виде "provider:model" — ещё и провайдера.
+type Registry struct {
+	providers map[string]Provider
+	def       Route
+	stages    map[string]Route
+	options   map[string]Options // этап → параметры по умолчанию
+	prices    PriceTable
+}
+
+// NewRegistry — проверяет, что каждый этап попадает в настроенного провайдера
+// и получает модель: ошибка конфигурации всплывает на старте, а не на первом чате
+func NewRegistry(
+	providers map[string]Provider,
+	def Route,
+	stages map[string]Route,
+	options map[string]Options,
+	prices PriceTable,
+) (*Registry, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("ai: no providers configured")
+	}
+	if _, ok := providers[def.Provider]; !ok {
+		return nil, fmt.Errorf("ai: default provider %q is not configured", def.Provider)
+	}
+	for stage, route := range stages {
+		if !IsStage(stage) {
+			return nil, fmt.Errorf("ai: unknown stage %q", stage)
+		}
+		if _, ok := providers[route.Provider]; !ok {
+			return nil, fmt.Errorf("ai: stage %s: provider %q is not configured", stage, route.Provider)
+		}
+	}
+	if err := ValidateStages(options); err != nil {
+		return nil, fmt.Errorf("ai: %w", err)
+	}
+
+	r := &Registry{providers: providers, def: def, stages: stages, options: options, prices: prices}
+	for _, stage := range AllStages {
+		opts := r.options[stage]
+		opts.Stage = stage
+		if _, err := r.resolve(opts); err != nil {
+			return nil, fmt.Errorf("ai: stage %s: %w", stage, err)
+		}
+	}
+	return r, nil
+}
+
+// Route — итоговый маршрут этапа с учётом модели тенанта
+func (r *Registry) Route(stage, model string) Route {
+	route, ok := r.stages[stage]
+	if !ok {
+		route = r.def
+	}
+	if model == "" {
+		return route
+	}
+
+	if override := ParseRoute(model); override.Model != "" {
+		if _, ok := r.providers[override.Provider]; ok {
+			return override
+		}
+	}
+	route.Model = model
+	return route
+}
+
+// resolve — провайдер и модель для уже слитых параметров этапа
+func (r *Registry) resolve(opts Options) (Route, error) {
+	route := r.Route(opts.Stage, opts.Model)
+	if route.Model == "" {
+		route.Model = r.providers[route.Provider].DefaultModel()
+	}
+	if route.Model == "" {
+		return route, fmt.Errorf("model is not set for provider %s", route.Provider)
+	}
+	return route, nil
+}
+
+// CheckModels — модели тенанта по этапам: "provider:model" должен указывать на
+// настроенного провайдера, иначе Route молча отдаст строку провайдеру этапа.
+// Модель с тегом через ':' пишется с провайдером: "ollama:llama3.1:8b"
+func (r *Registry) CheckModels(models map[string]Options) error {
+	for _, stage := range AllStages {
+		o, ok := models[stage]
+		if !ok || o.Model == "" {
+			continue
+		}
+		if strings.Contains(o.Model, ":") {
+			route := ParseRoute(o.Model)
+			if _, ok := r.providers[route.Provider]; !ok {
+				return fmt.Errorf("stage %s: model %q: provider %q is not configured", stage, o.Model, route.Provider)
+			}
+			if route.Model == "" {
+				return fmt.Errorf("stage %s: model %q: model name is empty", stage, o.Model)
+			}
+		}
+		opts := r.options[stage].Merge(o)
+		opts.Stage = stage
+		if _, err := r.resolve(opts); err != nil {
+			return fmt.Errorf("stage %s: %w", stage, err)
+		}
+	}
+	return nil
+}
+
+// Model — "provider:model", которую получит вызов с этими параметрами
+func (r *Registry) Model(opts Options) string {
+	route, _ := r.resolve(r.options[opts.Stage].Merge(opts))
+	return route.String()
+}
+
+// Stages — итоговые модели этапов для лога на старте
+func (r *Registry) Stages() []string {
+	out := make([]string, 0, len(AllStages))
+	for _, stage := range AllStages {
+		opts := r.options[stage]
+		opts.Stage = stage
+		route, _ := r.resolve(opts)
+		out = append(out, stage+"="+route.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Unpriced — модели этапов без цены: с бюджетом их траты не посчитаются
+func (r *Registry) Unpriced() []string {
+	var out []string
+	for _, stage := range AllStages {
+		opts := r.options[stage]
+		opts.Stage = stage
+		route, _ := r.resolve(opts)
+		if !r.prices.Priced(route.Provider, route.Model) {
+			out = append(out, stage+"="+route.String())
+		}
+	}
+	return out
+}
+
+func (r *Registry) GetReply(
+	ctx context.Context,
+	opts Options,
+	systemPrompt string,
+	inputJSON string,
+) (Reply, error) {
+
+	opts = r.options[opts.Stage].Merge(opts)
+	route, err := r.resolve(opts)
+	if err != nil {
+		return Reply{Provider: route.Provider}, fmt.Errorf("ai: stage %s: %w", opts.Stage, err)
+	}
+	opts.Model = route.Model
+
+	if t := opts.Timeout(); t > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+	}
+
+	ctx, span := tracing.Start(ctx, "ai.GetReply",
+		attribute.String("ai.stage", opts.Stage),
+		attribute.String("ai.provider", route.Provider),
+		attribute.String("ai.model", route.Model),
+	)
+
+	started := time.Now()
+	reply, err := r.providers[route.Provider].GetReply(ctx, opts, systemPrompt, inputJSON)
+	reply.Provider = route.Provider
+	reply.CostUSD, _ = r.prices.Cost(route.Provider, reply.Model, reply.Usage)
+	if reply.CostUSD > 0 {
+		metrics.AICost.WithLabelValues(route.Provider, reply.Model).Add(reply.CostUSD)
+	}
+	tracing.End(span, err,
+		attribute.String("ai.response_model", reply.Model),
+		attribute.Int("ai.prompt_tokens", reply.Usage.PromptTokens),
+		attribute.Int("ai.completion_tokens", reply.Usage.CompletionTokens),
+		attribute.Float64("ai.cost_usd", reply.CostUSD),
+	)
+	slog.DebugContext(ctx, "ai call",
+		"stage", opts.Stage,
+		"provider", route.Provider,
+		"model", reply.Model,
+		"duration", time.Since(started).String(),
+		"prompt_tokens", reply.Usage.PromptTokens,
+		"completion_tokens", reply.Usage.CompletionTokens,
+		"cost_usd", reply.CostUSD,
+	)
+	return reply, err
+}
+
+// RegistryFromEnv — провайдеры и маршруты этапов из окружения:
+//
+//	OPENAI_API_KEY, OPENAI_MODEL        — провайдер openai
+//	ANTHROPIC_API_KEY, ANTHROPIC_MODEL  — провайдер anthropic (ANTHROPIC_BASE_URL — необязательно)
+//	AI_COMPAT_PROVIDERS=ollama=http://ollama:11434/v1,vllm=http://vllm:8000/v1
+//	AI_COMPAT_<NAME>_API_KEY, AI_COMPAT_<NAME>_MODEL — для каждого совместимого
+//	AI_PROVIDER=openai                  — провайдер по умолчанию
+//	AI_STAGE_PROVIDERS=fact_selector=ollama,answer_builder=anthropic:claude-sonnet-4-5
+//	AI_STAGE_OPTIONS={"answer_builder":{"temperature":0.2,"max_tokens":1500,"timeout_seconds":60}}
+//	AI_PRICES={"gpt-4o-mini":{"input":0.15,"output":0.6}} — USD за 1M токенов, поверх DefaultPrices
+func RegistryFromEnv() (*Registry, error) {
+	providers := map[string]Provider{}
+
+	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+		c, err := NewOpenAIClient(OpenAIConfig{Name: "openai", APIKey: key, Model: os.Getenv("OPENAI_MODEL")})
+		if err != nil {
+			return nil, err
+		}
+		providers["openai"] = c
+	}
+
+	if key := os.Getenv("ANTHROPIC_API_KEY"); key != "" {
+		c, err := NewAnthropicClient(AnthropicConfig{
+			APIKey:  key,
+			BaseURL: os.Getenv("ANTHROPIC_BASE_URL"),
+			Model:   os.Getenv("ANTHROPIC_MODEL"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		providers["anthropic"] = c
+	}
+
+	for name, baseURL := range parsePairs(os.Getenv("AI_COMPAT_PROVIDERS")) {
+		if _, ok := providers[name]; ok {
+			return nil, fmt.Errorf("ai: provider %q is defined twice", name)
+		}
+		env := "AI_COMPAT_" + strings.ToUpper(name) + "_"
+		c, err := NewOpenAIClient(OpenAIConfig{
+			Name:    name,
+			APIKey:  os.Getenv(env + "API_KEY"),
+			BaseURL: baseURL,
+			Model:   os.Getenv(env + "MODEL"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		providers[name] = c
+	}
+
+	def := ParseRoute(os.Getenv("AI_PROVIDER"))
+	if def.Provider == "" {
+		def.Provider = "openai"
+	}
+
+	stages := map[string]Route{}
+	for stage, route := range parsePairs(os.Getenv("AI_STAGE_PROVIDERS")) {
+		stages[stage] = ParseRoute(route)
+	}
+
+	options := map[string]Options{}
+	if v := strings.TrimSpace(os.Getenv("AI_STAGE_OPTIONS")); v != "" {
+		if err := json.Unmarshal([]byte(v), &options); err != nil {
+			return nil, fmt.Errorf("ai: AI_STAGE_OPTIONS: %w", err)
+		}
+	}
+	for stage, o := range options {
+		o.Stage = stage
+		options[stage] = o
+	}
+
+	prices, err := PricesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRegistry(providers, def, stages, options, prices)
+}
+
+// parsePairs — "a=x,b=y" → {a: x, b: y}
+func parsePairs(v string) map[string]string {
+	out := map[string]string{}
+	for _, part := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(part, "=")
+		k, val = strings.TrimSpace(k), strings.TrimSpace(val)
+		if ok && k != "" && val != "" {
+			out[k] = val
+		}
+	}
+	return out
+}
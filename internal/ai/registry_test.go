@@ -0,0 +1,33 @@
+package ai
+
+import "testing"
+
+func TestRegistryCheckModels(t *testing.T) {
+	providers := map[string]Provider{"openai": modelProvider("gpt-4o-mini"), "ollama": modelProvider("")}
+	stages := map[string]Route{StageFactSelector: {Provider: "ollama", Model: "llama3.1:8b"}}
+	r, err := NewRegistry(providers, Route{Provider: "openai"}, stages, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		models  map[string]Options
+		wantErr bool
+	}{
+		{"no overrides", nil, false},
+		{"plain model", map[string]Options{StageAnswerBuilder: {Model: "gpt-5.2"}}, false},
+		{"configured provider", map[string]Options{StageAnswerBuilder: {Model: "ollama:llama3.1:8b"}}, false},
+		{"unknown provider", map[string]Options{StageAnswerBuilder: {Model: "gemini:pro"}}, true},
+		{"tagged model without provider", map[string]Options{StageFactSelector: {Model: "llama3.1:70b"}}, true},
+		{"provider without model", map[string]Options{StageAnswerBuilder: {Model: "ollama:"}}, true},
+		{"only options", map[string]Options{StageAnswerBuilder: {MaxTokens: 100}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.CheckModels(tt.models); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
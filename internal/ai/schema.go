@@ -0,0 +1,181 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Schema — JSON Schema ответа этапа (подмножество: object, array, string,
+// number, integer, boolean, enum). Уходит провайдеру как structured output
+// и по нему же проверяется ответ — провайдеры без строгого режима ошибаются.
+type Schema struct {
+	Type                 string             `json:"type"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+}
+
+// Object — все поля обязательны, лишних нет (так требует strict-режим OpenAI)
+func Object(props map[string]*Schema) *Schema {
+	required := make([]string, 0, len(props))
+	for name := range props {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	no := false
+	return &Schema{Type: "object", Properties: props, Required: required, AdditionalProperties: &no}
+}
+
+// MarshalJSON — go-openai ждёт схему как json.Marshaler
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	return json.Marshal((*plain)(s))
+}
+
+// schemaName — имя схемы для провайдера: [a-zA-Z0-9_-]
+func schemaName(stage string) string {
+	if stage == "" {
+		return "response"
+	}
+	return stage
+}
+
+func String() *Schema { return &Schema{Type: "string"} }
+
+func Enum(values ...string) *Schema { return &Schema{Type: "string", Enum: values} }
+
+func Array(items *Schema) *Schema { return &Schema{Type: "array", Items: items} }
+
+// Validate — ответ модели (уже разобранный в any) против схемы
+func (s *Schema) Validate(v any) error {
+	return s.validate(v, "$")
+}
+
+func (s *Schema) validate(v any, path string) error {
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %s", path, kind(v))
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s: required field is missing", path, name)
+			}
+		}
+		for name, val := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				continue // лишние поля не мешают разбору
+			}
+			if err := prop.validate(val, path+"."+name); err != nil {
+				return err
+			}
+		}
+
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %s", path, kind(v))
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %s", path, kind(v))
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %s", path, str, strings.Join(s.Enum, ", "))
+		}
+
+	case "number", "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: expected %s, got %s", path, s.Type, kind(v))
+		}
+		if s.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return fmt.Errorf("%s: expected integer, got %s", path, n)
+			}
+		}
+
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %s", path, kind(v))
+		}
+	}
+	return nil
+}
+
+// ExtractJSON — JSON-объект из ответа модели: без ```json-ограждений и текста
+// вокруг; проверен по схеме. Возвращает очищенный текст.
+func ExtractJSON(text string, schema *Schema) (string, error) {
+	s := strings.TrimSpace(text)
+
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		if nl := strings.IndexByte(s, '\n'); nl >= 0 {
+			s = s[nl+1:] // ```json
+		}
+		if end := strings.LastIndex(s, "```"); end >= 0 {
+			s = s[:end]
+		}
+		s = strings.TrimSpace(s)
+	}
+
+	// пояснения до и после объекта
+	start, end := strings.IndexByte(s, '{'), strings.LastIndexByte(s, '}')
+	if start < 0 || end < start {
+		return "", fmt.Errorf("no JSON object in response")
+	}
+	s = s[start : end+1]
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	if dec.More() {
+		return "", fmt.Errorf("trailing data after JSON object")
+	}
+
+	if schema != nil {
+		if err := schema.Validate(v); err != nil {
+			return "", err
+		}
+	}
+	return s, nil
+}
+
+func kind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,148 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := Object(map[string]*Schema{
+		"mode":  Enum("SELF_CONFIDENCE", "LOW_CONFIDENCE"),
+		"facts": Array(String()),
+		"score": {Type: "number"},
+		"count": {Type: "integer"},
+		"ok":    {Type: "boolean"},
+	})
+
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string // подстрока ошибки; "" — без ошибки
+	}{
+		{"valid", `{"mode":"SELF_CONFIDENCE","facts":["a"],"score":0.5,"count":2,"ok":true}`, ""},
+		{"extra field ignored", `{"mode":"LOW_CONFIDENCE","facts":[],"score":1,"count":0,"ok":false,"x":1}`, ""},
+		{"not an object", `[1]`, "$: expected object, got array"},
+		{"missing field", `{"mode":"SELF_CONFIDENCE","facts":[],"score":1,"count":0}`, "$.ok: required field is missing"},
+		{"enum", `{"mode":"MAYBE","facts":[],"score":1,"count":0,"ok":true}`, `$.mode: "MAYBE" is not one of`},
+		{"array item", `{"mode":"SELF_CONFIDENCE","facts":["a",2],"score":1,"count":0,"ok":true}`, "$.facts[1]: expected string, got number"},
+		{"null instead of array", `{"mode":"SELF_CONFIDENCE","facts":null,"score":1,"count":0,"ok":true}`, "$.facts: expected array, got null"},
+		{"fractional integer", `{"mode":"SELF_CONFIDENCE","facts":[],"score":1,"count":1.5,"ok":true}`, "$.count: expected integer"},
+		{"string number", `{"mode":"SELF_CONFIDENCE","facts":[],"score":"1","count":0,"ok":true}`, "$.score: expected number, got string"},
+		{"string boolean", `{"mode":"SELF_CONFIDENCE","facts":[],"score":1,"count":0,"ok":"true"}`, "$.ok: expected boolean, got string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(decode(t, tt.json))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("err = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	schema := Object(map[string]*Schema{"mode": Enum("SELF_CONFIDENCE", "LOW_CONFIDENCE")})
+
+	tests := []struct {
+		name    string
+		text    string
+		schema  *Schema
+		want    string
+		wantErr bool
+	}{
+		{"plain", `{"mode":"SELF_CONFIDENCE"}`, schema, `{"mode":"SELF_CONFIDENCE"}`, false},
+		{"fenced", "```json\n{\"mode\":\"SELF_CONFIDENCE\"}\n```", schema, `{"mode":"SELF_CONFIDENCE"}`, false},
+		{"fenced without language", "```\n{\"mode\":\"LOW_CONFIDENCE\"}\n```", schema, `{"mode":"LOW_CONFIDENCE"}`, false},
+		{"text around", "Вот ответ:\n{\"mode\":\"SELF_CONFIDENCE\"}\nГотово.", schema, `{"mode":"SELF_CONFIDENCE"}`, false},
+		{"braces inside strings", `{"mode":"SELF_CONFIDENCE","note":"a } b { c"}`, schema, `{"mode":"SELF_CONFIDENCE","note":"a } b { c"}`, false},
+		{"no object", "не знаю", schema, "", true},
+		{"broken json", `{"mode":}`, schema, "", true},
+		{"two objects", `{"mode":"SELF_CONFIDENCE"} {"mode":"LOW_CONFIDENCE"}`, schema, "", true},
+		{"schema violation", `{"mode":"MAYBE"}`, schema, "", true},
+		{"without schema", `{"anything":1}`, nil, `{"anything":1}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractJSON(tt.text, tt.schema)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractJSON = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// scriptedAI — ответы по очереди, запоминает системные промпты
+type scriptedAI struct {
+	replies []string
+	prompts []string
+}
+
+func (a *scriptedAI) GetReply(_ context.Context, _ Options, system, _ string) (Reply, error) {
+	a.prompts = append(a.prompts, system)
+	text := a.replies[0]
+	a.replies = a.replies[1:]
+	return Reply{Text: text, Usage: Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, CostUSD: 0.01}, nil
+}
+
+func TestStructuredRepair(t *testing.T) {
+	opts := Options{Stage: StageAnswerValidator, Schema: Object(map[string]*Schema{"mode": Enum("SELF_CONFIDENCE")})}
+
+	tests := []struct {
+		name     string
+		replies  []string
+		wantText string
+		wantErr  error
+		calls    int
+	}{
+		{"valid first time", []string{`{"mode":"SELF_CONFIDENCE"}`}, `{"mode":"SELF_CONFIDENCE"}`, nil, 1},
+		{"repaired", []string{`mode: SELF_CONFIDENCE`, "```json\n{\"mode\":\"SELF_CONFIDENCE\"}\n```"}, `{"mode":"SELF_CONFIDENCE"}`, nil, 2},
+		{"still invalid", []string{`нет`, `{"mode":"NO"}`}, "", ErrInvalidJSON, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &scriptedAI{replies: tt.replies}
+			reply, err := NewStructured(next).GetReply(context.Background(), opts, "system", "{}")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && reply.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", reply.Text, tt.wantText)
+			}
+			if len(next.prompts) != tt.calls {
+				t.Fatalf("calls = %d, want %d", len(next.prompts), tt.calls)
+			}
+			if tt.calls == 2 {
+				if !strings.Contains(next.prompts[1], tt.replies[0]) {
+					t.Error("repair prompt must echo the previous answer")
+				}
+				if reply.Usage.TotalTokens != 30 || reply.CostUSD != 0.02 {
+					t.Errorf("usage %d, cost %v, want both attempts summed", reply.Usage.TotalTokens, reply.CostUSD)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/metrics"
+)
+
+// ErrInvalidJSON — модель так и не вернула JSON по схеме этапа
+var ErrInvalidJSON = errors.New("invalid json response")
+
+// maxRepairEcho — сколько прошлого ответа показать модели при починке
+const maxRepairEcho = 2000
+
+// Structured — проверка ответов по opts.Schema поверх любого AI: ответ очищается
+// от ограждений и текста вокруг, не прошёл схему — один повтор с ошибкой в промпте.
+// Text в Reply — уже чистый JSON; Usage и CostUSD — суммарно за обе попытки.
+type Structured struct {
+	next AI
+}
+
+func NewStructured(next AI) *Structured {
+	return &Structured{next: next}
+}
+
+func (s *Structured) GetReply(
+	ctx context.Context,
+	opts Options,
+	systemPrompt string,
+	inputJSON string,
+) (Reply, error) {
+
+	reply, err := s.next.GetReply(ctx, opts, systemPrompt, inputJSON)
+	if err != nil || opts.Schema == nil {
+		return reply, err
+	}
+
+	text, verr := ExtractJSON(reply.Text, opts.Schema)
+	if verr == nil {
+		reply.Text = text
+		metrics.AIParse.WithLabelValues(opts.Stage, "ok").Inc()
+		return reply, nil
+	}
+
+	slog.WarnContext(ctx, "ai: invalid json, repairing", "stage", opts.Stage, "err", verr)
+	trace.SpanFromContext(ctx).AddEvent("ai json repair", trace.WithAttributes(attribute.String("error", verr.Error())))
+
+	retry, err := s.next.GetReply(ctx, opts, repairPrompt(systemPrompt, reply.Text, verr), inputJSON)
+	retry.Usage = addUsage(reply.Usage, retry.Usage)
+	retry.CostUSD += reply.CostUSD
+	if err != nil {
+		metrics.AIParse.WithLabelValues(opts.Stage, "failed").Inc()
+		return retry, err
+	}
+
+	text, rerr := ExtractJSON(retry.Text, opts.Schema)
+	if rerr != nil {
+		metrics.AIParse.WithLabelValues(opts.Stage, "failed").Inc()
+		return retry, fmt.Errorf("%w: %v (first attempt: %v)", ErrInvalidJSON, rerr, verr)
+	}
+
+	retry.Text = text
+	metrics.AIParse.WithLabelValues(opts.Stage, "repaired").Inc()
+	return retry, nil
+}
+
+func repairPrompt(systemPrompt, previous string, verr error) string {
+	if len(previous) > maxRepairEcho {
+		previous = previous[:maxRepairEcho] + "..."
+	}
+	return systemPrompt + `
+
+ВНИМАНИЕ: твой предыдущий ответ не прошёл проверку формата.
+Ошибка: ` + verr.Error() + `
+Предыдущий ответ:
+` + previous + `
+
+Верни ТОЛЬКО JSON-объект в требуемом формате — без пояснений и без ` + "```" + `.`
+}
+
+func addUsage(a, b Usage) Usage {
+	return Usage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+	}
+}
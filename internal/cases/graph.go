@@ -0,0 +1,188 @@
+package cases
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GraphError — битые связи между кейсами
+type GraphError struct {
+	Dangling []string   // "CASE_01 → CASE_99"
+	Cycles   [][]string // CASE_01 → CASE_02 → CASE_01
+}
+
+func (e *GraphError) Error() string {
+	var parts []string
+	if len(e.Dangling) > 0 {
+		parts = append(parts, "dangling refs: "+strings.Join(e.Dangling, ", "))
+	}
+	for _, c := range e.Cycles {
+		parts = append(parts, "cycle: "+strings.Join(c, " → "))
+	}
+	return "case graph: " + strings.Join(parts, "; ")
+}
+
+func (e *GraphError) Unwrap() error {
+	return ErrInvalid
+}
+
+// ValidateGraph — висячие ссылки и циклы в prerequisites / next
+func ValidateGraph(list []Case) error {
+	byID := index(list)
+	gerr := &GraphError{}
+
+	for _, c := range sorted(list) {
+		for _, ref := range c.Prerequisites {
+			if _, ok := byID[ref]; !ok {
+				gerr.Dangling = append(gerr.Dangling, c.ID+" → "+ref)
+			}
+		}
+		for _, ref := range c.Next {
+			if _, ok := byID[ref]; !ok {
+				gerr.Dangling = append(gerr.Dangling, c.ID+" → "+ref)
+			}
+		}
+	}
+
+	gerr.Cycles = append(gerr.Cycles, findCycles(list, byID, func(c Case) []string { return c.Prerequisites })...)
+	gerr.Cycles = append(gerr.Cycles, findCycles(list, byID, func(c Case) []string { return c.Next })...)
+
+	if len(gerr.Dangling) > 0 || len(gerr.Cycles) > 0 {
+		return gerr
+	}
+	return nil
+}
+
+// ResolvedCase — кейс с транзитивно раскрытыми предпосылками в порядке проверки
+type ResolvedCase struct {
+	Case
+	Before []string // сначала эти кейсы, по порядку
+}
+
+// Resolve — кейсы в порядке проверки: предпосылки раньше зависящих от них.
+// Граф должен быть проверен ValidateGraph.
+func Resolve(list []Case) []ResolvedCase {
+	byID := index(list)
+
+	var order []string
+	done := map[string]bool{}
+	var visit func(id string)
+	visit = func(id string) {
+		if done[id] {
+			return
+		}
+		done[id] = true
+		for _, ref := range byID[id].Prerequisites {
+			visit(ref)
+		}
+		order = append(order, id)
+	}
+	for _, c := range sorted(list) {
+		visit(c.ID)
+	}
+
+	out := make([]ResolvedCase, 0, len(order))
+	for _, id := range order {
+		out = append(out, ResolvedCase{
+			Case:   byID[id],
+			Before: prerequisitesOf(id, byID),
+		})
+	}
+	return out
+}
+
+// ------------------------------------------------------------
+
+// prerequisitesOf — все предпосылки кейса: глубже лежащие первыми, без повторов
+func prerequisitesOf(id string, byID map[string]Case) []string {
+	var out []string
+	seen := map[string]bool{id: true}
+	var visit func(id string)
+	visit = func(id string) {
+		for _, ref := range byID[id].Prerequisites {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			visit(ref)
+			out = append(out, ref)
+		}
+	}
+	visit(id)
+	return out
+}
+
+func findCycles(list []Case, byID map[string]Case, edges func(Case) []string) [][]string {
+	const (
+		white = iota
+		grey
+		black
+	)
+
+	color := map[string]int{}
+	var stack []string
+	var cycles [][]string
+
+	var visit func(id string)
+	visit = func(id string) {
+		color[id] = grey
+		stack = append(stack, id)
+
+		for _, ref := range edges(byID[id]) {
+			if _, ok := byID[ref]; !ok {
+				continue
+			}
+			switch color[ref] {
+			case white:
+				visit(ref)
+			case grey:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == ref {
+						cycle := append([]string{}, stack[i:]...)
+						cycles = append(cycles, append(cycle, ref))
+						break
+					}
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		color[id] = black
+	}
+
+	for _, c := range sorted(list) {
+		if color[c.ID] == white {
+			visit(c.ID)
+		}
+	}
+
+	return cycles
+}
+
+func index(list []Case) map[string]Case {
+	out := make(map[string]Case, len(list))
+	for _, c := range list {
+		out[c.ID] = c
+	}
+	return out
+}
+
+// sorted — порядок по умолчанию: приоритет, затем id
+func sorted(list []Case) []Case {
+	out := append([]Case{}, list...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Priority != out[j].Priority {
+			return out[i].Priority > out[j].Priority
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func titleOf(id string, byID map[string]Case) string {
+	if c, ok := byID[id]; ok {
+		return fmt.Sprintf("%s_%s", c.ID, c.Title)
+	}
+	return id
+}
@@ -0,0 +1,127 @@
+package cases
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func kase(id string, prerequisites, next []string) Case {
+	return Case{TenantID: "t", ID: id, Title: id, Prerequisites: prerequisites, Next: next}
+}
+
+func TestValidateGraph(t *testing.T) {
+	tests := []struct {
+		name         string
+		list         []Case
+		wantDangling []string
+		wantCycles   [][]string
+	}{
+		{"empty", nil, nil, nil},
+		{
+			"valid chain",
+			[]Case{kase("CASE_01", nil, []string{"CASE_02"}), kase("CASE_02", []string{"CASE_03"}, nil), kase("CASE_03", nil, nil)},
+			nil, nil,
+		},
+		{
+			"dangling prerequisite and next",
+			[]Case{kase("CASE_01", []string{"CASE_98"}, []string{"CASE_99"})},
+			[]string{"CASE_01 → CASE_98", "CASE_01 → CASE_99"}, nil,
+		},
+		{
+			"prerequisite cycle",
+			[]Case{kase("CASE_01", []string{"CASE_02"}, nil), kase("CASE_02", []string{"CASE_01"}, nil)},
+			nil, [][]string{{"CASE_01", "CASE_02", "CASE_01"}},
+		},
+		{
+			"next cycle of three",
+			[]Case{kase("CASE_01", nil, []string{"CASE_02"}), kase("CASE_02", nil, []string{"CASE_03"}), kase("CASE_03", nil, []string{"CASE_01"})},
+			nil, [][]string{{"CASE_01", "CASE_02", "CASE_03", "CASE_01"}},
+		},
+		{
+			"prerequisite and next in opposite directions are fine",
+			[]Case{kase("CASE_01", []string{"CASE_02"}, nil), kase("CASE_02", nil, []string{"CASE_01"})},
+			nil, nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateGraph(tt.list)
+			if tt.wantDangling == nil && tt.wantCycles == nil {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+
+			var gerr *GraphError
+			if !errors.As(err, &gerr) {
+				t.Fatalf("err = %v, want GraphError", err)
+			}
+			if !errors.Is(err, ErrInvalid) {
+				t.Error("GraphError must unwrap to ErrInvalid")
+			}
+			if !reflect.DeepEqual(gerr.Dangling, tt.wantDangling) {
+				t.Errorf("dangling = %v, want %v", gerr.Dangling, tt.wantDangling)
+			}
+			if !reflect.DeepEqual(gerr.Cycles, tt.wantCycles) {
+				t.Errorf("cycles = %v, want %v", gerr.Cycles, tt.wantCycles)
+			}
+		})
+	}
+}
+
+func TestGraphCheck(t *testing.T) {
+	current := []Case{
+		kase("CASE_01", nil, []string{"CASE_02"}),
+		kase("CASE_02", nil, nil),
+	}
+	changed := func(c Case) *Case { return &c }
+
+	tests := []struct {
+		name    string
+		changed *Case
+		deleted string
+		wantErr bool
+	}{
+		{"add unrelated case", changed(kase("CASE_03", nil, nil)), "", false},
+		{"add case with link to existing", changed(kase("CASE_03", []string{"CASE_01"}, nil)), "", false},
+		{"add case with dangling link", changed(kase("CASE_03", nil, []string{"CASE_09"})), "", true},
+		{"update closes a cycle", changed(kase("CASE_02", nil, []string{"CASE_01"})), "", true},
+		{"update replaces old links", changed(kase("CASE_01", nil, nil)), "", false},
+		{"delete referenced case", nil, "CASE_02", true},
+		{"delete case nobody refers to", nil, "CASE_01", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := graphCheck(tt.changed, tt.deleted)(current)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	list := []Case{
+		kase("CASE_01", []string{"CASE_02"}, nil),
+		kase("CASE_02", []string{"CASE_03"}, nil),
+		kase("CASE_03", nil, nil),
+	}
+
+	var order []string
+	before := map[string][]string{}
+	for _, rc := range Resolve(list) {
+		order = append(order, rc.ID)
+		before[rc.ID] = rc.Before
+	}
+
+	if want := []string{"CASE_03", "CASE_02", "CASE_01"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if want := []string{"CASE_03", "CASE_02"}; !reflect.DeepEqual(before["CASE_01"], want) {
+		t.Errorf("CASE_01 before = %v, want %v", before["CASE_01"], want)
+	}
+}
@@ -0,0 +1,173 @@
+package cases
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/httpx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	list, err := h.svc.List(r.Context(), tenantOf(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if list == nil {
+		list = []Case{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	c, err := h.svc.Get(r.Context(), tenantOf(r), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	var c Case
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	c.TenantID = tenantOf(r)
+
+	if err := h.svc.Create(r.Context(), &c, httpx.AdminUser(r)); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "cases: created", "tenant", c.TenantID, "case", c.ID, "rev", c.Revision, "by", httpx.AdminUser(r))
+	writeJSON(w, http.StatusCreated, c)
+}
+
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	var c Case
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	c.TenantID = tenantOf(r)
+	c.ID = chi.URLParam(r, "id")
+
+	if err := h.svc.Update(r.Context(), &c, httpx.AdminUser(r)); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "cases: updated", "tenant", c.TenantID, "case", c.ID, "rev", c.Revision, "by", httpx.AdminUser(r))
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.Delete(r.Context(), tenantOf(r), id, httpx.AdminUser(r)); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "cases: deleted", "tenant", tenantOf(r), "case", id, "by", httpx.AdminUser(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) Revisions(w http.ResponseWriter, r *http.Request) {
+	list, err := h.svc.Revisions(r.Context(), tenantOf(r), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if list == nil {
+		list = []Revision{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *Handler) Rollback(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	revID, err := strconv.ParseInt(chi.URLParam(r, "revision"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.svc.Rollback(r.Context(), tenantOf(r), id, revID, httpx.AdminUser(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "cases: rollback", "tenant", tenantOf(r), "case", id, "rev", revID, "by", httpx.AdminUser(r))
+	if c == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) AffectedMessages(w http.ResponseWriter, r *http.Request) {
+	revID, err := strconv.ParseInt(chi.URLParam(r, "revision"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.svc.AffectedMessages(r.Context(), tenantOf(r), chi.URLParam(r, "id"), revID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if list == nil {
+		list = []AffectedMessage{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// ------------------------------------------------------------
+
+// tenantOf — /admin/tenants/{tenant}/cases или /admin/cases (тенант default)
+func tenantOf(r *http.Request) string {
+	if t := chi.URLParam(r, "tenant"); t != "" {
+		return t
+	}
+	return tenant.DefaultID
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		slog.Error("cases: error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
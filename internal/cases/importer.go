@@ -0,0 +1,144 @@
+package cases
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+var (
+	caseHeaderRe = regexp.MustCompile(`(?m)^(CASE_\d{2})_(.+):[ \t]*$`)
+	caseRefRe    = regexp.MustCompile(`CASE_\d{2}`)
+)
+
+// Parse — разбирает старый текстовый промпт (NotVPNDomainPrompt) на кейсы
+func Parse(text string) []Case {
+	headers := caseHeaderRe.FindAllStringSubmatchIndex(text, -1)
+
+	out := make([]Case, 0, len(headers))
+	refs := make([][]string, 0, len(headers))
+	for i, h := range headers {
+		end := len(text)
+		if i+1 < len(headers) {
+			end = headers[i+1][0]
+		}
+
+		c := Case{
+			ID:    text[h[2]:h[3]],
+			Title: strings.TrimSpace(text[h[4]:h[5]]),
+		}
+
+		body := text[h[1]:end]
+
+		var triggers []string
+		var steps []string
+		for _, line := range strings.Split(body, "\n") {
+			line = strings.TrimSpace(line)
+			if t, ok := strings.CutPrefix(line, "ПРИЗНАК:"); ok {
+				triggers = append(triggers, strings.TrimSpace(t))
+				continue
+			}
+			if line == "" && (len(steps) == 0 || steps[len(steps)-1] == "") {
+				continue
+			}
+			steps = append(steps, line)
+		}
+
+		c.Triggers = strings.Join(triggers, "\n")
+		c.Steps = strings.TrimSpace(strings.Join(steps, "\n"))
+		c.Platforms = parsePlatforms(c.Title, body)
+		c.Priority = parsePriority(body)
+
+		out = append(out, c)
+		refs = append(refs, parseRefs(c.ID, body))
+	}
+
+	// ссылка на приоритетный кейс («сначала CASE_25») — предпосылка, остальные — куда идти дальше
+	priority := make(map[string]int, len(out))
+	for _, c := range out {
+		priority[c.ID] = c.Priority
+	}
+	for i := range out {
+		for _, ref := range refs[i] {
+			if priority[ref] > 0 {
+				out[i].Prerequisites = append(out[i].Prerequisites, ref)
+			} else {
+				out[i].Next = append(out[i].Next, ref)
+			}
+		}
+	}
+
+	return out
+}
+
+// ImportIfEmpty — одноразовый импорт: заливает кейсы из текста, если у тенанта их нет
+func ImportIfEmpty(ctx context.Context, repo Repo, tenantID string, text string) error {
+	n, err := repo.Count(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	parsed := Parse(text)
+	if err := ValidateGraph(parsed); err != nil {
+		return err
+	}
+
+	for i := range parsed {
+		parsed[i].TenantID = tenantID
+		// граф проверен целиком выше, по одному кейсу ссылки ещё висят
+		if err := repo.Create(ctx, &parsed[i], "import", nil); err != nil && !errors.Is(err, ErrExists) {
+			return err
+		}
+	}
+
+	slog.InfoContext(ctx, "cases: imported", "count", len(parsed), "tenant", tenantID)
+	return nil
+}
+
+// ------------------------------------------------------------
+
+func parsePlatforms(title string, body string) []string {
+	upper := strings.ToUpper(title)
+	hasIOS := strings.Contains(upper, "IOS")
+	hasAndroid := strings.Contains(upper, "ANDROID")
+
+	switch {
+	case hasIOS && !hasAndroid:
+		return []string{"ios"}
+	case hasAndroid && !hasIOS:
+		return []string{"android"}
+	case strings.Contains(strings.ToLower(body), "не применять для ios"):
+		return []string{"android"}
+	default:
+		return nil
+	}
+}
+
+func parsePriority(body string) int {
+	switch {
+	case strings.Contains(body, "РАНЬШЕ ВСЕХ"):
+		return 100
+	case strings.Contains(body, "ПРАВИЛО ПРИОРИТЕТА"):
+		return 50
+	default:
+		return 0
+	}
+}
+
+func parseRefs(selfID string, body string) []string {
+	var out []string
+	seen := map[string]bool{selfID: true}
+	for _, id := range caseRefRe.FindAllString(body, -1) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
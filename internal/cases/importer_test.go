@@ -0,0 +1,103 @@
+package cases
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	const prompt = `Вступление без кейсов.
+
+CASE_01_VPN_NOT_STARTS:
+ПРИЗНАК: не включается
+ПРИЗНАК: висит на подключении
+
+
+Сначала проверь CASE_25, потом CASE_07.
+Перезапусти приложение.
+
+CASE_07_IOS_PROFILE:
+ПРИЗНАК: нет профиля
+Установи профиль.
+
+CASE_25_SUBSCRIPTION:
+ПРАВИЛО ПРИОРИТЕТА: проверять раньше
+Проверь подписку, не применять для iOS.
+`
+
+	got := Parse(prompt)
+
+	want := []Case{
+		{
+			ID:       "CASE_01",
+			Title:    "VPN_NOT_STARTS",
+			Triggers: "не включается\nвисит на подключении",
+			Steps:    "Сначала проверь CASE_25, потом CASE_07.\nПерезапусти приложение.",
+
+			Prerequisites: []string{"CASE_25"},
+			Next:          []string{"CASE_07"},
+		},
+		{
+			ID:        "CASE_07",
+			Title:     "IOS_PROFILE",
+			Triggers:  "нет профиля",
+			Steps:     "Установи профиль.",
+			Platforms: []string{"ios"},
+		},
+		{
+			ID:        "CASE_25",
+			Title:     "SUBSCRIPTION",
+			Steps:     "ПРАВИЛО ПРИОРИТЕТА: проверять раньше\nПроверь подписку, не применять для iOS.",
+			Platforms: []string{"android"},
+			Priority:  50,
+		},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("parsed %d cases, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("case %d:\n got %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+	if err := ValidateGraph(got); err != nil {
+		t.Errorf("parsed graph: %v", err)
+	}
+}
+
+func TestParseHelpers(t *testing.T) {
+	platforms := []struct {
+		title, body string
+		want        []string
+	}{
+		{"IOS_PROFILE", "", []string{"ios"}},
+		{"ANDROID_BATTERY", "", []string{"android"}},
+		{"IOS_ANDROID_COMMON", "", nil},
+		{"SUBSCRIPTION", "Не применять для iOS", []string{"android"}},
+		{"SUBSCRIPTION", "", nil},
+	}
+	for _, tt := range platforms {
+		if got := parsePlatforms(tt.title, tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePlatforms(%q, %q) = %v, want %v", tt.title, tt.body, got, tt.want)
+		}
+	}
+
+	priorities := []struct {
+		body string
+		want int
+	}{
+		{"РАНЬШЕ ВСЕХ проверь", 100},
+		{"ПРАВИЛО ПРИОРИТЕТА", 50},
+		{"обычный кейс", 0},
+	}
+	for _, tt := range priorities {
+		if got := parsePriority(tt.body); got != tt.want {
+			t.Errorf("parsePriority(%q) = %d, want %d", tt.body, got, tt.want)
+		}
+	}
+
+	if got, want := parseRefs("CASE_01", "CASE_02, CASE_01, CASE_02, CASE_03"), []string{"CASE_02", "CASE_03"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRefs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,342 @@
+package cases
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+const caseColumns = `tenant_id, id, title, triggers, steps, platforms, priority, prerequisites, next, revision, extract(epoch from updated_at)::bigint`
+
+func (r *repo) List(ctx context.Context, tenantID string) ([]Case, error) {
+	return list(ctx, r.db, tenantID)
+}
+
+// queryer — *sql.DB или *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func list(ctx context.Context, q queryer, tenantID string) ([]Case, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+caseColumns+`
+		FROM cases
+		WHERE tenant_id = $1
+		ORDER BY priority DESC, id ASC
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Case
+	for rows.Next() {
+		var c Case
+		if err := scanCase(rows, &c); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+
+	return out, rows.Err()
+}
+
+func (r *repo) Get(ctx context.Context, tenantID string, id string) (*Case, error) {
+	var c Case
+	err := scanCase(r.db.QueryRowContext(ctx, `
+		SELECT `+caseColumns+`
+		FROM cases
+		WHERE tenant_id = $1 AND id = $2
+	`, tenantID, id), &c)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *repo) Create(ctx context.Context, c *Case, author string, check GraphCheck) error {
+	return r.withTenantTx(ctx, c.TenantID, check, func(tx *sql.Tx) error {
+		return saveTx(ctx, tx, c, author, true)
+	})
+}
+
+func (r *repo) Update(ctx context.Context, c *Case, author string, check GraphCheck) error {
+	return r.withTenantTx(ctx, c.TenantID, check, func(tx *sql.Tx) error {
+		var one int
+		err := tx.QueryRowContext(ctx,
+			`SELECT 1 FROM cases WHERE tenant_id = $1 AND id = $2 FOR UPDATE`, c.TenantID, c.ID,
+		).Scan(&one)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return saveTx(ctx, tx, c, author, false)
+	})
+}
+
+func (r *repo) Delete(ctx context.Context, tenantID string, id string, author string, check GraphCheck) error {
+	return r.withTenantTx(ctx, tenantID, check, func(tx *sql.Tx) error {
+		var c Case
+		err := scanCase(tx.QueryRowContext(ctx, `
+			SELECT `+caseColumns+`
+			FROM cases
+			WHERE tenant_id = $1 AND id = $2
+			FOR UPDATE
+		`, tenantID, id), &c)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := insertRevision(ctx, tx, &c, true, author); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM cases WHERE tenant_id = $1 AND id = $2`, tenantID, id)
+		return err
+	})
+}
+
+func (r *repo) Count(ctx context.Context, tenantID string) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT count(*) FROM cases WHERE tenant_id = $1`, tenantID).Scan(&n)
+	return n, err
+}
+
+// ------------------------------------------------------------
+
+const revisionColumns = `id, tenant_id, case_id, title, triggers, steps, platforms, priority, prerequisites, next, deleted, author, extract(epoch from created_at)::bigint`
+
+func (r *repo) Revisions(ctx context.Context, tenantID string, caseID string) ([]Revision, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+revisionColumns+`
+		FROM case_revisions
+		WHERE tenant_id = $1 AND case_id = $2
+		ORDER BY id DESC
+	`, tenantID, caseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Revision
+	for rows.Next() {
+		var rev Revision
+		if err := scanRevision(rows, &rev); err != nil {
+			return nil, err
+		}
+		out = append(out, rev)
+	}
+
+	return out, rows.Err()
+}
+
+func (r *repo) Revision(ctx context.Context, id int64) (*Revision, error) {
+	var rev Revision
+	err := scanRevision(r.db.QueryRowContext(ctx, `
+		SELECT `+revisionColumns+`
+		FROM case_revisions
+		WHERE id = $1
+	`, id), &rev)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+func (r *repo) AffectedMessages(ctx context.Context, revisionID int64, limit int) ([]AffectedMessage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.id, m.chat_id, m.text, extract(epoch from m.created_at)::bigint
+		FROM message_case_revisions mcr
+		JOIN messages m ON m.id = mcr.message_id
+		WHERE mcr.revision_id = $1
+		ORDER BY m.id DESC
+		LIMIT $2
+	`, revisionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AffectedMessage
+	for rows.Next() {
+		var m AffectedMessage
+		if err := rows.Scan(&m.MessageID, &m.ChatID, &m.Text, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+
+	return out, rows.Err()
+}
+
+// ------------------------------------------------------------
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCase(row scanner, c *Case) error {
+	return row.Scan(
+		&c.TenantID,
+		&c.ID,
+		&c.Title,
+		&c.Triggers,
+		&c.Steps,
+		pq.Array(&c.Platforms),
+		&c.Priority,
+		pq.Array(&c.Prerequisites),
+		pq.Array(&c.Next),
+		&c.Revision,
+		&c.UpdatedAt,
+	)
+}
+
+func scanRevision(row scanner, rev *Revision) error {
+	return row.Scan(
+		&rev.ID,
+		&rev.Case.TenantID,
+		&rev.Case.ID,
+		&rev.Case.Title,
+		&rev.Case.Triggers,
+		&rev.Case.Steps,
+		pq.Array(&rev.Case.Platforms),
+		&rev.Case.Priority,
+		pq.Array(&rev.Case.Prerequisites),
+		pq.Array(&rev.Case.Next),
+		&rev.Deleted,
+		&rev.Author,
+		&rev.CreatedAt,
+	)
+}
+
+func (r *repo) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// withTenantTx — транзакция под advisory-локом тенанта: правки его кейсов
+// идут по одной, check видит набор, который не поменяется до коммита
+func (r *repo) withTenantTx(ctx context.Context, tenantID string, check GraphCheck, fn func(tx *sql.Tx) error) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "cases:"+tenantID); err != nil {
+			return err
+		}
+		if check != nil {
+			current, err := list(ctx, tx, tenantID)
+			if err != nil {
+				return err
+			}
+			if err := check(current); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// saveTx — новая ревизия + текущее состояние кейса
+func saveTx(ctx context.Context, tx *sql.Tx, c *Case, author string, create bool) error {
+	revID, err := insertRevision(ctx, tx, c, false, author)
+	if err != nil {
+		return err
+	}
+
+	onConflict := `DO UPDATE SET
+			title = EXCLUDED.title,
+			triggers = EXCLUDED.triggers,
+			steps = EXCLUDED.steps,
+			platforms = EXCLUDED.platforms,
+			priority = EXCLUDED.priority,
+			prerequisites = EXCLUDED.prerequisites,
+			next = EXCLUDED.next,
+			revision = EXCLUDED.revision,
+			updated_at = now()`
+	if create {
+		onConflict = `DO NOTHING`
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO cases (tenant_id, id, title, triggers, steps, platforms, priority, prerequisites, next, revision)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (tenant_id, id) `+onConflict+`
+		RETURNING extract(epoch from updated_at)::bigint
+	`,
+		c.TenantID,
+		c.ID,
+		c.Title,
+		c.Triggers,
+		c.Steps,
+		pq.Array(nonNil(c.Platforms)),
+		c.Priority,
+		pq.Array(nonNil(c.Prerequisites)),
+		pq.Array(nonNil(c.Next)),
+		revID,
+	).Scan(&c.UpdatedAt)
+	if create && errors.Is(err, sql.ErrNoRows) {
+		return ErrExists
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Revision = revID
+	return nil
+}
+
+func insertRevision(ctx context.Context, tx *sql.Tx, c *Case, deleted bool, author string) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO case_revisions (tenant_id, case_id, title, triggers, steps, platforms, priority, prerequisites, next, deleted, author)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`,
+		c.TenantID,
+		c.ID,
+		c.Title,
+		c.Triggers,
+		c.Steps,
+		pq.Array(nonNil(c.Platforms)),
+		c.Priority,
+		pq.Array(nonNil(c.Prerequisites)),
+		pq.Array(nonNil(c.Next)),
+		deleted,
+		author,
+	).Scan(&id)
+	return id, err
+}
+
+// NOT NULL DEFAULT '{}' — nil-слайс pq отдаёт как NULL
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package cases
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/dbtest"
+)
+
+func TestConcurrentUpdatesCannotCloseCycle(t *testing.T) {
+	svc := NewService(NewRepo(dbtest.Open(t)))
+	ctx := context.Background()
+
+	for _, id := range []string{"CASE_01", "CASE_02"} {
+		c := kase(id, nil, nil)
+		if err := svc.Create(ctx, &c, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// каждая правка по отдельности допустима, вместе — цикл
+	updates := []Case{
+		kase("CASE_01", nil, []string{"CASE_02"}),
+		kase("CASE_02", nil, []string{"CASE_01"}),
+	}
+	errs := make([]error, len(updates))
+	var wg sync.WaitGroup
+	for i := range updates {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = svc.Update(ctx, &updates[i], "test")
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrInvalid):
+			failed++
+		default:
+			t.Fatal(err)
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("errors = %v, want exactly one update rejected", errs)
+	}
+
+	list, err := svc.List(ctx, "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateGraph(list); err != nil {
+		t.Errorf("stored graph is broken: %v", err)
+	}
+}
+
+func TestDeleteReferencedCase(t *testing.T) {
+	svc := NewService(NewRepo(dbtest.Open(t)))
+	ctx := context.Background()
+
+	target := kase("CASE_02", nil, nil)
+	from := kase("CASE_01", nil, []string{"CASE_02"})
+	for _, c := range []*Case{&target, &from} {
+		if err := svc.Create(ctx, c, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := svc.Delete(ctx, "t", "CASE_02", "test"); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("err = %v, want ErrInvalid for a referenced case", err)
+	}
+	if _, err := svc.Get(ctx, "t", "CASE_02"); err != nil {
+		t.Errorf("case deleted despite the error: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package cases
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Loader — собирает поле "cases" для FACT SELECTOR из таблицы.
+// БД недоступна — отдаёт последний собранный текст тенанта
+type Loader struct {
+	repo Repo
+
+	mu       sync.Mutex
+	lastGood map[string]casesPrompt
+}
+
+type casesPrompt struct {
+	text      string
+	revisions map[string]int64
+}
+
+func NewLoader(repo Repo) *Loader {
+	return &Loader{repo: repo, lastGood: map[string]casesPrompt{}}
+}
+
+// Validate — проверка графа связей при старте
+func (l *Loader) Validate(ctx context.Context, tenantID string) error {
+	list, err := l.repo.List(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	return ValidateGraph(list)
+}
+
+// CasesPrompt — текст для промпта + ревизии кейсов, из которых он собран.
+// Ошибка чтения — последний удачный текст тенанта, если он был;
+// кейсов нет совсем — ErrNoCases: без них модели отвечать не из чего
+func (l *Loader) CasesPrompt(ctx context.Context, tenantID string) (string, map[string]int64, error) {
+	text, revisions, err := l.load(ctx, tenantID)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err == nil {
+		l.lastGood[tenantID] = casesPrompt{text: text, revisions: revisions}
+		return text, revisions, nil
+	}
+	if errors.Is(err, ErrNoCases) {
+		delete(l.lastGood, tenantID)
+		return "", nil, err
+	}
+	last, ok := l.lastGood[tenantID]
+	if !ok || ctx.Err() != nil {
+		return "", nil, err
+	}
+	slog.WarnContext(ctx, "cases: load failed, using last good prompt", "tenant", tenantID, "err", err)
+	return last.text, last.revisions, nil
+}
+
+func (l *Loader) load(ctx context.Context, tenantID string) (string, map[string]int64, error) {
+	list, err := l.repo.List(ctx, tenantID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(list) == 0 {
+		return "", nil, ErrNoCases
+	}
+	if err := ValidateGraph(list); err != nil {
+		return "", nil, err
+	}
+
+	revisions := make(map[string]int64, len(list))
+	for _, c := range list {
+		revisions[c.ID] = c.Revision
+	}
+
+	return Render(list), revisions, nil
+}
+
+// Render — кейсы в порядке проверки, предпосылки уже раскрыты
+func Render(list []Case) string {
+	byID := index(list)
+
+	var b strings.Builder
+
+	for _, c := range Resolve(list) {
+		b.WriteString(c.ID + "_" + c.Title + ":\n")
+
+		if len(c.Before) > 0 {
+			b.WriteString("СНАЧАЛА (по порядку): " + joinTitles(c.Before, " → ", byID) + "\n")
+		}
+		if c.Triggers != "" {
+			b.WriteString("ПРИЗНАК: " + c.Triggers + "\n")
+		}
+		if len(c.Platforms) > 0 {
+			b.WriteString("ПЛАТФОРМЫ: " + strings.Join(c.Platforms, ", ") + "\n")
+		}
+		if c.Steps != "" {
+			b.WriteString(c.Steps + "\n")
+		}
+		if len(c.Next) > 0 {
+			b.WriteString("ЕСЛИ НЕ ПОМОГЛО: " + joinTitles(c.Next, ", ", byID) + "\n")
+		}
+
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func joinTitles(ids []string, sep string, byID map[string]Case) string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, titleOf(id, byID))
+	}
+	return strings.Join(out, sep)
+}
@@ -0,0 +1,64 @@
+package cases
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// listRepo — List отдаёт то, что лежит в полях
+type listRepo struct {
+	Repo
+	list []Case
+	err  error
+}
+
+func (r *listRepo) List(context.Context, string) ([]Case, error) {
+	return r.list, r.err
+}
+
+func TestLoaderCasesPrompt(t *testing.T) {
+	good := []Case{{TenantID: "t", ID: "CASE_01", Title: "VPN", Revision: 5}}
+	dbDown := errors.New("db is down")
+
+	tests := []struct {
+		name     string
+		before   []Case // удачная загрузка до шага; nil — её не было
+		list     []Case
+		err      error
+		wantErr  error
+		wantText string
+	}{
+		{"loads cases", nil, good, nil, nil, "CASE_01_VPN"},
+		{"no cases", nil, nil, nil, ErrNoCases, ""},
+		{"db error without last good", nil, nil, dbDown, dbDown, ""},
+		{"db error falls back to last good", good, nil, dbDown, nil, "CASE_01_VPN"},
+		{"broken graph falls back to last good", good, []Case{{ID: "CASE_02", Next: []string{"CASE_09"}}}, nil, nil, "CASE_01_VPN"},
+		{"emptied base does not use stale prompt", good, nil, nil, ErrNoCases, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &listRepo{list: tt.before}
+			l := NewLoader(repo)
+			if tt.before != nil {
+				if _, _, err := l.CasesPrompt(context.Background(), "t"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			repo.list, repo.err = tt.list, tt.err
+			text, revisions, err := l.CasesPrompt(context.Background(), "t")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(text, tt.wantText) || (tt.wantText == "" && text != "") {
+				t.Errorf("text = %q, want it to contain %q", text, tt.wantText)
+			}
+			if tt.wantText != "" && revisions["CASE_01"] != 5 {
+				t.Errorf("revisions = %v, want CASE_01 → 5", revisions)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package cases
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrNotFound = errors.New("case not found")
+	ErrExists   = errors.New("case already exists")
+	ErrInvalid  = errors.New("invalid case")
+	ErrNoCases  = errors.New("tenant has no cases")
+)
+
+// Case — один кейс базы знаний поддержки
+type Case struct {
+	TenantID  string   `json:"tenant_id"`
+	ID        string   `json:"id"`        // CASE_01
+	Title     string   `json:"title"`     // VPN_NOT_STARTS
+	Triggers  string   `json:"triggers"`  // ПРИЗНАК
+	Steps     string   `json:"steps"`     // всё остальное тело кейса
+	Platforms []string `json:"platforms"` // android | ios; пусто — любая платформа
+	Priority  int      `json:"priority"`  // чем выше, тем раньше проверяется
+
+	Prerequisites []string `json:"prerequisites"` // что проверить до этого кейса: CASE_25, CASE_26
+	Next          []string `json:"next"`          // куда идти, если не помогло: CASE_07, CASE_09
+
+	Revision  int64 `json:"revision"` // id текущей ревизии
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// Revision — неизменяемый снимок кейса после правки
+type Revision struct {
+	ID        int64  `json:"id"`
+	Case      Case   `json:"case"`
+	Deleted   bool   `json:"deleted"`
+	Author    string `json:"author"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// AffectedMessage — сообщение, на которое отвечали с участием ревизии
+type AffectedMessage struct {
+	MessageID int64  `json:"message_id"`
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// GraphCheck — проверка кейсов тенанта перед записью; nil — без проверки
+type GraphCheck func(current []Case) error
+
+// Repo — persistence; каждая запись создаёт ревизию.
+// Кейсы живут в наборе своего тенанта. Записи тенанта идут по одной
+// (advisory-лок на транзакцию), check видит кейсы внутри той же транзакции:
+// две параллельные правки не соберут цикл или висячую ссылку.
+type Repo interface {
+	List(ctx context.Context, tenantID string) ([]Case, error)
+	Get(ctx context.Context, tenantID string, id string) (*Case, error)
+	Create(ctx context.Context, c *Case, author string, check GraphCheck) error
+	Update(ctx context.Context, c *Case, author string, check GraphCheck) error
+	Delete(ctx context.Context, tenantID string, id string, author string, check GraphCheck) error
+	Count(ctx context.Context, tenantID string) (int, error)
+
+	Revisions(ctx context.Context, tenantID string, caseID string) ([]Revision, error)
+	Revision(ctx context.Context, id int64) (*Revision, error)
+	AffectedMessages(ctx context.Context, revisionID int64, limit int) ([]AffectedMessage, error)
+}
+
+// Service — правки кейсов с проверками и откатом
+type Service interface {
+	List(ctx context.Context, tenantID string) ([]Case, error)
+	Get(ctx context.Context, tenantID string, id string) (*Case, error)
+	Create(ctx context.Context, c *Case, author string) error
+	Update(ctx context.Context, c *Case, author string) error
+	Delete(ctx context.Context, tenantID string, id string, author string) error
+
+	Revisions(ctx context.Context, tenantID string, caseID string) ([]Revision, error)
+	Rollback(ctx context.Context, tenantID string, caseID string, revisionID int64, author string) (*Case, error)
+	AffectedMessages(ctx context.Context, tenantID string, caseID string, revisionID int64) ([]AffectedMessage, error)
+}
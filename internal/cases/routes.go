@@ -0,0 +1,15 @@
+package cases
+
+import "github.com/go-chi/chi/v5"
+
+// RegisterAdminRoutes — пути относительно /admin/cases
+func RegisterAdminRoutes(r chi.Router, h *Handler) {
+	r.Get("/", h.List)
+	r.Post("/", h.Create)
+	r.Get("/{id}", h.Get)
+	r.Put("/{id}", h.Update)
+	r.Delete("/{id}", h.Delete)
+	r.Get("/{id}/revisions", h.Revisions)
+	r.Get("/{id}/revisions/{revision}/messages", h.AffectedMessages)
+	r.Post("/{id}/rollback/{revision}", h.Rollback)
+}
@@ -0,0 +1,154 @@
+package cases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var caseIDRe = regexp.MustCompile(`^CASE_\d{2}$`)
+
+var knownPlatforms = map[string]bool{
+	"android": true,
+	"ios":     true,
+}
+
+type service struct {
+	repo Repo
+}
+
+func NewService(repo Repo) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) List(ctx context.Context, tenantID string) ([]Case, error) {
+	return s.repo.List(ctx, tenantID)
+}
+
+func (s *service) Get(ctx context.Context, tenantID string, id string) (*Case, error) {
+	return s.repo.Get(ctx, tenantID, id)
+}
+
+func (s *service) Create(ctx context.Context, c *Case, author string) error {
+	if err := validate(c); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, c, author, graphCheck(c, ""))
+}
+
+func (s *service) Update(ctx context.Context, c *Case, author string) error {
+	if err := validate(c); err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, c, author, graphCheck(c, ""))
+}
+
+func (s *service) Delete(ctx context.Context, tenantID string, id string, author string) error {
+	return s.repo.Delete(ctx, tenantID, id, author, graphCheck(nil, id))
+}
+
+func (s *service) Revisions(ctx context.Context, tenantID string, caseID string) ([]Revision, error) {
+	return s.repo.Revisions(ctx, tenantID, caseID)
+}
+
+// Rollback — новая ревизия с содержимым старой; история не переписывается
+func (s *service) Rollback(ctx context.Context, tenantID string, caseID string, revisionID int64, author string) (*Case, error) {
+	rev, err := s.repo.Revision(ctx, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if rev.Case.TenantID != tenantID || rev.Case.ID != caseID {
+		return nil, ErrNotFound
+	}
+
+	_, err = s.repo.Get(ctx, tenantID, caseID)
+	exists := err == nil
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if rev.Deleted {
+		if exists {
+			if err := s.Delete(ctx, tenantID, caseID, author); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+
+	c := rev.Case
+	if exists {
+		err = s.Update(ctx, &c, author)
+	} else {
+		err = s.Create(ctx, &c, author)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (s *service) AffectedMessages(ctx context.Context, tenantID string, caseID string, revisionID int64) ([]AffectedMessage, error) {
+	rev, err := s.repo.Revision(ctx, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if rev.Case.TenantID != tenantID || rev.Case.ID != caseID {
+		return nil, ErrNotFound
+	}
+	return s.repo.AffectedMessages(ctx, revisionID, 200)
+}
+
+// ------------------------------------------------------------
+
+func validate(c *Case) error {
+	if c.TenantID == "" {
+		return fmt.Errorf("%w: tenant is empty", ErrInvalid)
+	}
+
+	c.ID = strings.TrimSpace(c.ID)
+	c.Title = strings.TrimSpace(c.Title)
+
+	if !caseIDRe.MatchString(c.ID) {
+		return fmt.Errorf("%w: id must look like CASE_01", ErrInvalid)
+	}
+	if c.Title == "" {
+		return fmt.Errorf("%w: title is empty", ErrInvalid)
+	}
+	for _, p := range c.Platforms {
+		if !knownPlatforms[p] {
+			return fmt.Errorf("%w: unknown platform %q", ErrInvalid, p)
+		}
+	}
+	for _, ref := range append(append([]string{}, c.Prerequisites...), c.Next...) {
+		if !caseIDRe.MatchString(ref) {
+			return fmt.Errorf("%w: bad ref %q", ErrInvalid, ref)
+		}
+		if ref == c.ID {
+			return fmt.Errorf("%w: case refers to itself", ErrInvalid)
+		}
+	}
+
+	return nil
+}
+
+// graphCheck — граф после правки: changed заменяет/добавляет кейс, deleted убирает.
+// Репозиторий вызывает её в транзакции записи, на актуальном наборе кейсов
+func graphCheck(changed *Case, deleted string) GraphCheck {
+	return func(current []Case) error {
+		next := make([]Case, 0, len(current)+1)
+		for _, c := range current {
+			if c.ID == deleted || (changed != nil && c.ID == changed.ID) {
+				continue
+			}
+			next = append(next, c)
+		}
+		if changed != nil {
+			next = append(next, *changed)
+		}
+		return ValidateGraph(next)
+	}
+}
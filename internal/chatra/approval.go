@@ -0,0 +1,164 @@
+package chatra
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/logx"
+)
+
+// Approvals — черновики ответов под подтверждение оператора (режим approve).
+// Оператор получает в заметке ссылку с токеном и одним кликом отправляет,
+// правит или отклоняет черновик. Токен короткоживущий, в БД лежит только его хеш.
+type Approvals struct {
+	drafts  Drafts
+	repo    Repo
+	ttl     time.Duration
+	baseURL string // публичный адрес моста для ссылок в заметках
+}
+
+func NewApprovals(drafts Drafts, repo Repo, ttl time.Duration, baseURL string) *Approvals {
+	return &Approvals{
+		drafts:  drafts,
+		repo:    repo,
+		ttl:     ttl,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// Issue — сохранить черновик и вернуть ссылку для оператора
+func (a *Approvals) Issue(ctx context.Context, msg *Message, runID int64, text string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	d := &Draft{
+		TenantID:  msg.TenantID,
+		ChatID:    msg.ChatID,
+		ClientID:  *msg.ClientID,
+		MessageID: msg.ID,
+		RunID:     runID,
+		Text:      text,
+		ExpiresAt: time.Now().Add(a.ttl),
+	}
+	if err := a.drafts.Create(ctx, d, hashToken(token)); err != nil {
+		return "", err
+	}
+
+	slog.InfoContext(ctx, "approval draft issued", "draft_id", d.ID, "expires_at", d.ExpiresAt.Format(time.RFC3339))
+	return a.baseURL + "/approve/" + token, nil
+}
+
+// Get — черновик по токену из ссылки
+func (a *Approvals) Get(ctx context.Context, token string) (*Draft, error) {
+	return a.drafts.ByToken(ctx, hashToken(token))
+}
+
+// Approve — отправить черновик клиенту; edited == "" — без правок.
+// claimedBy — имя из формы, ничем не подтверждено
+func (a *Approvals) Approve(ctx context.Context, token, edited, claimedBy string) (*Draft, error) {
+	d, err := a.drafts.ByToken(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	ctx = logx.With(ctx, "draft_id", d.ID, "tenant", d.TenantID, "chat_id", d.ChatID, "run_id", d.RunID)
+
+	text := d.Text
+	if edited = strings.TrimSpace(edited); edited != "" {
+		text = edited
+	}
+
+	// сначала забираем черновик себе: два клика не отправят ответ дважды
+	if err := a.drafts.Decide(ctx, d.ID, DraftApproved, claimedBy); err != nil {
+		return nil, err
+	}
+
+	// сообщение AI и его отправка — одной транзакцией, доставит outbox
+	sent := &Message{
+		TenantID: d.TenantID,
+		ChatID:   d.ChatID,
+		Sender:   SenderAI,
+		Text:     text,
+		ClientID: &d.ClientID,
+		ReplyTo:  d.MessageID,
+	}
+	if err := a.repo.SaveReply(ctx, sent); err != nil {
+		if rerr := a.drafts.Reopen(context.WithoutCancel(ctx), d.ID); rerr != nil {
+			slog.ErrorContext(ctx, "approval draft reopen failed", "err", rerr)
+		}
+		return nil, err
+	}
+
+	distance := editDistance(d.Text, text)
+	if err := a.drafts.Sent(ctx, d.ID, text, distance, sent.ID); err != nil {
+		slog.ErrorContext(ctx, "approval result save failed", "err", err)
+	}
+
+	d.Status = DraftApproved
+	d.DecidedByClaimed = claimedBy
+	d.FinalText = text
+	d.EditDistance = distance
+	d.SentMessageID = sent.ID
+
+	slog.InfoContext(ctx, "approval draft approved", "claimed_by", claimedBy, "edit_distance", distance)
+	return d, nil
+}
+
+// Reject — черновик не отправляется
+func (a *Approvals) Reject(ctx context.Context, token, claimedBy string) (*Draft, error) {
+	d, err := a.drafts.ByToken(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if err := a.drafts.Decide(ctx, d.ID, DraftRejected, claimedBy); err != nil {
+		return nil, err
+	}
+
+	d.Status = DraftRejected
+	d.DecidedByClaimed = claimedBy
+
+	slog.InfoContext(ctx, "approval draft rejected", "draft_id", d.ID, "tenant", d.TenantID, "chat_id", d.ChatID, "claimed_by", claimedBy)
+	return d, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// editDistance — Левенштейн по символам: насколько оператор переписал черновик
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
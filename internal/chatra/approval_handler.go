@@ -0,0 +1,136 @@
+package chatra
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const approvalFormMaxBytes = 64 << 10
+
+// ApprovalHandler — страницы по ссылке из заметки; доступ — только по токену
+type ApprovalHandler struct {
+	approvals *Approvals
+}
+
+func NewApprovalHandler(approvals *Approvals) *ApprovalHandler {
+	return &ApprovalHandler{approvals: approvals}
+}
+
+// Page — GET /approve/{token}, GET /reject/{token}: черновик и форма.
+// Сам GET ничего не меняет — ссылку могут открыть превью мессенджеров.
+func (h *ApprovalHandler) Page(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	d, err := h.approvals.Get(r.Context(), token)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	h.render(w, http.StatusOK, d, token, "")
+}
+
+// Approve — POST /approve/{token}: отправить как есть или с правкой из формы
+func (h *ApprovalHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, approvalFormMaxBytes)
+	token := chi.URLParam(r, "token")
+
+	d, err := h.approvals.Approve(r.Context(), token, r.PostFormValue("text"), claimedOperator(r))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	h.render(w, http.StatusOK, d, token, "Ответ поставлен в отправку клиенту")
+}
+
+// Reject — POST /reject/{token}
+func (h *ApprovalHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, approvalFormMaxBytes)
+	token := chi.URLParam(r, "token")
+
+	d, err := h.approvals.Reject(r.Context(), token, claimedOperator(r))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	h.render(w, http.StatusOK, d, token, "Черновик отклонён")
+}
+
+// claimedOperator — имя, которое оператор ввёл сам; страница открыта по токену
+// без входа, так что это только заявление, не личность
+func claimedOperator(r *http.Request) string {
+	return strings.TrimSpace(r.PostFormValue("operator"))
+}
+
+func (h *ApprovalHandler) fail(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrDraftNotFound):
+		http.Error(w, "Черновик не найден", http.StatusNotFound)
+	case errors.Is(err, ErrDraftClosed):
+		http.Error(w, "Черновик уже обработан или ссылка истекла", http.StatusConflict)
+	default:
+		slog.Error("approval failed", "err", err)
+		http.Error(w, "Не удалось выполнить, попробуйте ещё раз", http.StatusBadGateway)
+	}
+}
+
+func (h *ApprovalHandler) render(w http.ResponseWriter, status int, d *Draft, token, notice string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer") // токен в пути не должен утекать дальше
+	w.WriteHeader(status)
+
+	if err := approvalPage.Execute(w, map[string]any{
+		"Draft":  d,
+		"Token":  token,
+		"Notice": notice,
+		"Open":   d.Open(time.Now()),
+	}); err != nil {
+		slog.Error("approval page render failed", "err", err)
+	}
+}
+
+var approvalPage = template.Must(template.New("approve").Parse(`<!doctype html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Черновик ответа</title>
+<style>
+  body { font: 14px/1.45 system-ui, sans-serif; margin: 0 auto; max-width: 720px; padding: 16px; color: #222; }
+  textarea { width: 100%; min-height: 180px; font: inherit; }
+  .muted { color: #888; }
+  .notice { padding: 8px 12px; background: #eef8ee; border-radius: 6px; }
+  .draft { white-space: pre-wrap; background: #fafafa; padding: 8px; border-left: 3px solid #ccc; }
+</style>
+</head>
+<body>
+<h1>Черновик ответа</h1>
+<p class="muted">Чат {{.Draft.ChatID}} · создан {{.Draft.CreatedAt.Format "02.01 15:04"}} · действует до {{.Draft.ExpiresAt.Format "02.01 15:04"}}</p>
+{{if .Notice}}<p class="notice">{{.Notice}}</p>{{end}}
+
+{{if .Open}}
+<form method="post" action="/approve/{{.Token}}">
+  <textarea name="text">{{.Draft.Text}}</textarea>
+  <p><input name="operator" placeholder="ваше имя (не проверяется)"></p>
+  <button>Отправить клиенту</button>
+  <button formaction="/reject/{{.Token}}">Отклонить</button>
+</form>
+{{else}}
+<p>Статус: <b>{{.Draft.Status}}</b>{{if .Draft.DecidedByClaimed}} — со слов: {{.Draft.DecidedByClaimed}}{{end}}</p>
+{{if .Draft.FinalText}}
+<p>Отправлено:</p>
+<div class="draft">{{.Draft.FinalText}}</div>
+{{if .Draft.EditDistance}}<p class="muted">Правок: {{.Draft.EditDistance}} симв.</p>{{end}}
+{{else}}
+<div class="draft">{{.Draft.Text}}</div>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,114 @@
+package chatra
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type drafts struct {
+	db *sql.DB
+}
+
+func NewDrafts(db *sql.DB) Drafts {
+	return &drafts{db: db}
+}
+
+func nullID(id int64) *int64 {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+func (r *drafts) Create(ctx context.Context, d *Draft, tokenHash string) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO drafts (tenant_id, chat_id, client_id, message_id, run_id, token_hash, text, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, status, created_at
+	`,
+		d.TenantID,
+		d.ChatID,
+		d.ClientID,
+		nullID(d.MessageID),
+		nullID(d.RunID),
+		tokenHash,
+		d.Text,
+		d.ExpiresAt,
+	).Scan(&d.ID, &d.Status, &d.CreatedAt)
+}
+
+func (r *drafts) ByToken(ctx context.Context, tokenHash string) (*Draft, error) {
+	var d Draft
+	var finalText sql.NullString
+	var editDistance sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, tenant_id, chat_id, client_id, COALESCE(message_id, 0), COALESCE(run_id, 0),
+		       text, final_text, edit_distance, status, decided_by_claimed, decided_at,
+		       COALESCE(sent_message_id, 0), expires_at, created_at
+		FROM drafts
+		WHERE token_hash = $1
+	`, tokenHash).Scan(
+		&d.ID,
+		&d.TenantID,
+		&d.ChatID,
+		&d.ClientID,
+		&d.MessageID,
+		&d.RunID,
+		&d.Text,
+		&finalText,
+		&editDistance,
+		&d.Status,
+		&d.DecidedByClaimed,
+		&d.DecidedAt,
+		&d.SentMessageID,
+		&d.ExpiresAt,
+		&d.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDraftNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.FinalText = finalText.String
+	d.EditDistance = int(editDistance.Int64)
+	return &d, nil
+}
+
+func (r *drafts) Decide(ctx context.Context, id int64, status DraftStatus, claimedBy string) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE drafts
+		SET status = $2, decided_by_claimed = $3, decided_at = now()
+		WHERE id = $1 AND status = 'pending' AND expires_at > now()
+	`, id, string(status), claimedBy)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDraftClosed
+	}
+	return nil
+}
+
+func (r *drafts) Reopen(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE drafts
+		SET status = 'pending', decided_by_claimed = '', decided_at = NULL
+		WHERE id = $1 AND sent_message_id IS NULL
+	`, id)
+	return err
+}
+
+func (r *drafts) Sent(ctx context.Context, id int64, finalText string, editDistance int, sentMessageID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE drafts
+		SET final_text = $2, edit_distance = $3, sent_message_id = $4
+		WHERE id = $1
+	`, id, finalText, editDistance, nullID(sentMessageID))
+	return err
+}
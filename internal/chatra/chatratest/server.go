@@ -0,0 +1,189 @@
+// Package chatratest — заглушка REST API Chatra для интеграционных тестов.
+// Записывает все запросы, отдаёт заготовленные ответы, а без них ведёт себя
+// как Chatra: хранит заметки клиентов и принимает pushedMessages.
+package chatratest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/chatra"
+)
+
+// apiPrefix — как у настоящего https://app.chatra.io/api
+const apiPrefix = "/api"
+
+// Request — запрос, который пришёл в заглушку
+type Request struct {
+	Method string
+	Path   string // без /api
+	Auth   string // заголовок Authorization
+	Body   []byte
+}
+
+// JSON — тело запроса как map; nil, если это не JSON-объект
+func (r Request) JSON() map[string]any {
+	var m map[string]any
+	_ = json.Unmarshal(r.Body, &m)
+	return m
+}
+
+// Response — заготовленный ответ
+type Response struct {
+	Status int
+	Body   string
+	Header http.Header
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []Request
+	canned   map[string][]Response // "METHOD /path" → очередь, последний повторяется
+	notes    map[string]string     // clientId → notes
+}
+
+func NewServer() *Server {
+	s := &Server{
+		canned: map[string][]Response{},
+		notes:  map[string]string{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Config — конфиг исходящего клиента, смотрящий в заглушку
+func (s *Server) Config() chatra.ChatraConfig {
+	return chatra.ChatraConfig{BaseURL: s.URL + apiPrefix}
+}
+
+// Transport — отправляет любой запрос в заглушку, сохраняя путь;
+// подходит, если base URL менять нельзя
+func (s *Server) Transport() http.RoundTripper {
+	target, _ := url.Parse(s.URL)
+	base := s.Client().Transport
+	return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.URL.Scheme = target.Scheme
+		r.URL.Host = target.Host
+		r.Host = target.Host
+		return base.RoundTrip(r)
+	})
+}
+
+// On — ответы на METHOD path по очереди; последний повторяется дальше
+func (s *Server) On(method, path string, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.canned[method+" "+path] = responses
+}
+
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) Notes(clientID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notes[clientID]
+}
+
+func (s *Server) SetNotes(clientID, notes string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notes[clientID] = notes
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   path,
+		Auth:   r.Header.Get("Authorization"),
+		Body:   body,
+	})
+	resp, ok := s.next(r.Method + " " + path)
+	s.mu.Unlock()
+
+	if ok {
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		status := resp.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, resp.Body)
+		return
+	}
+
+	s.fallback(w, r.Method, path, body)
+}
+
+// next — очередной заготовленный ответ; вызывать под mu
+func (s *Server) next(key string) (Response, bool) {
+	queue := s.canned[key]
+	if len(queue) == 0 {
+		return Response{}, false
+	}
+	resp := queue[0]
+	if len(queue) > 1 {
+		s.canned[key] = queue[1:]
+	}
+	return resp, true
+}
+
+// fallback — поведение Chatra по умолчанию
+func (s *Server) fallback(w http.ResponseWriter, method, path string, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case method == http.MethodPost && path == "/pushedMessages":
+		_, _ = io.WriteString(w, `{"ok":true}`)
+
+	case strings.HasPrefix(path, "/clients/"):
+		clientID := strings.TrimPrefix(path, "/clients/")
+		switch method {
+		case http.MethodGet:
+			s.mu.Lock()
+			notes := s.notes[clientID]
+			s.mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": clientID, "notes": notes})
+		case http.MethodPut:
+			var in struct {
+				Notes *string `json:"notes"`
+			}
+			if err := json.Unmarshal(body, &in); err != nil {
+				http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+				return
+			}
+			if in.Notes != nil {
+				s.SetNotes(clientID, *in.Notes)
+			}
+			_, _ = io.WriteString(w, `{"ok":true}`)
+		default:
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+
+	default:
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,49 @@
+package chatra
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// Окно тишины живёт в очереди задач: фрагмент с окном ждёт, пока клиент не замолчит
+// на DEBOUNCE_SECONDS, и воркер забирает все накопленные фрагменты чата одной пачкой.
+// Задачи пачки завершаются только после ответа — рестарт ничего не теряет.
+// Если клиент пишет, пока пачка в работе, воркер отменяет прогон и возвращает
+// пачку в очередь: она уйдёт одним ответом вместе с новым сообщением.
+
+// HandleBurst — фрагменты чата, накопленные за окно тишины, по порядку:
+// все сообщения сохраняются, на новые сообщения клиента — один ответ
+func (s *service) HandleBurst(ctx context.Context, fs []*Fragment) error {
+	var pending []*Message
+	for _, f := range fs {
+		if err := s.saveFragment(ctx, f, func(msg *Message) error {
+			pending = append(pending, msg)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	slog.InfoContext(ctx, "debounce: run pipeline", "fragments", len(fs), "messages", len(pending))
+	return s.answer(ctx, mergeMessages(pending))
+}
+
+// mergeMessages — одно сообщение из пачки: тексты по порядку, остальное от последнего
+func mergeMessages(msgs []*Message) *Message {
+	if len(msgs) == 1 {
+		return msgs[0]
+	}
+
+	texts := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		texts = append(texts, m.Text)
+	}
+
+	merged := *msgs[len(msgs)-1]
+	merged.Text = strings.Join(texts, "\n")
+	return &merged
+}
@@ -0,0 +1,351 @@
+package chatra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/ai"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/jobs"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/pipeline"
+)
+
+func burstFragment(ids ...string) *Fragment {
+	f := &Fragment{TenantID: "t", ChatID: "chat", ClientID: "client"}
+	for _, id := range ids {
+		f.Messages = append(f.Messages, FragmentMessage{ID: id, Type: "client", Text: "текст " + id})
+	}
+	return f
+}
+
+func TestHandleBurstAnswersOnce(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := newTestService(repo)
+
+	fs := []*Fragment{burstFragment("m1"), burstFragment("m2", "m3")}
+	if err := svc.HandleBurst(context.Background(), fs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.messages) != 3 {
+		t.Fatalf("saved %d messages, want 3", len(repo.messages))
+	}
+	if len(repo.replies) != 1 {
+		t.Fatalf("replies = %d, want one for the whole burst", len(repo.replies))
+	}
+	if got := repo.replies[0].ReplyTo; got != 3 {
+		t.Errorf("reply to message %d, want the last one (3)", got)
+	}
+}
+
+func TestHandleBurstRetryAfterReply(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := newTestService(repo)
+
+	fs := []*Fragment{burstFragment("m1"), burstFragment("m2")}
+	if err := svc.HandleBurst(context.Background(), fs); err != nil {
+		t.Fatal(err)
+	}
+
+	// ответ записан, но задача не успела завершиться — пачка пришла снова
+	for _, f := range fs {
+		f.Retry = true
+	}
+	if err := svc.HandleBurst(context.Background(), fs); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.replies) != 1 {
+		t.Errorf("replies = %d, want 1", len(repo.replies))
+	}
+}
+
+func TestHandleBurstFailedReplyIsRetried(t *testing.T) {
+	repo := &fakeRepo{failReply: map[int64]int{2: 1}}
+	svc := newTestService(repo)
+
+	fs := []*Fragment{burstFragment("m1"), burstFragment("m2")}
+	if err := svc.HandleBurst(context.Background(), fs); err == nil {
+		t.Fatal("want error: reply not saved, job must not be acked")
+	}
+	for _, f := range fs {
+		f.Retry = true
+	}
+	if err := svc.HandleBurst(context.Background(), fs); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.replies) != 1 || repo.replies[0].ReplyTo != 2 {
+		t.Errorf("replies = %+v, want one reply to message 2", repo.replies)
+	}
+}
+
+// spyService — какой метод выбрал обработчик задач
+type spyService struct {
+	Service
+	single *Fragment
+	burst  []*Fragment
+}
+
+func (s *spyService) HandleFragment(_ context.Context, f *Fragment) error {
+	s.single = f
+	return nil
+}
+
+func (s *spyService) HandleBurst(_ context.Context, fs []*Fragment) error {
+	s.burst = fs
+	return nil
+}
+
+func TestJobHandlerBatch(t *testing.T) {
+	job := func(id int64, attempts int, f *Fragment) *jobs.Job {
+		b, _ := json.Marshal(f)
+		return &jobs.Job{ID: id, Kind: JobKindFragment, Payload: b, Attempts: attempts}
+	}
+
+	t.Run("without window", func(t *testing.T) {
+		spy := &spyService{}
+		if err := NewJobHandler(spy)(context.Background(), job(1, 1, burstFragment("m1"))); err != nil {
+			t.Fatal(err)
+		}
+		if spy.single == nil || spy.burst != nil {
+			t.Fatalf("want HandleFragment, got single=%v burst=%v", spy.single, spy.burst)
+		}
+	})
+
+	t.Run("window batch in order", func(t *testing.T) {
+		spy := &spyService{}
+		head := job(1, 2, burstFragment("m1"))
+		head.Debounce = time.Second
+		head.Batch = []*jobs.Job{job(2, 1, burstFragment("m2"))}
+
+		if err := NewJobHandler(spy)(context.Background(), head); err != nil {
+			t.Fatal(err)
+		}
+		if len(spy.burst) != 2 {
+			t.Fatalf("burst = %d fragments, want 2", len(spy.burst))
+		}
+		if spy.burst[0].Messages[0].ID != "m1" || spy.burst[1].Messages[0].ID != "m2" {
+			t.Errorf("burst order broken")
+		}
+		if !spy.burst[0].Retry || spy.burst[1].Retry {
+			t.Errorf("retry flags = %v %v, want true false", spy.burst[0].Retry, spy.burst[1].Retry)
+		}
+	})
+}
+
+// fakeRuns — трассы прогонов в памяти
+type fakeRuns struct {
+	pipeline.Repo
+	finished []pipeline.Run
+}
+
+func (r *fakeRuns) StartRun(_ context.Context, run *pipeline.Run) error {
+	run.ID = int64(len(r.finished) + 1)
+	return nil
+}
+func (r *fakeRuns) SaveStep(context.Context, *pipeline.Step) error { return nil }
+func (r *fakeRuns) FinishRun(_ context.Context, run *pipeline.Run) error {
+	r.finished = append(r.finished, *run)
+	return nil
+}
+
+// cancellingAI — процесс останавливается посреди вызова модели
+type cancellingAI struct{ cancel context.CancelFunc }
+
+func (a cancellingAI) GetReply(ctx context.Context, _ ai.Options, _, _ string) (ai.Reply, error) {
+	a.cancel()
+	return ai.Reply{}, ctx.Err()
+}
+
+func TestAnswerInterruptedIsNotAIError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := &fakeRuns{}
+	repo := &fakeRepo{}
+	svc := NewService(repo, cancellingAI{cancel}, staticCases("CASE_01"), liveTenants{}, runs, nil, nil).(*service)
+
+	clientID := "client"
+	err := svc.answer(ctx, &Message{ID: 1, TenantID: "t", ChatID: "chat", Text: "вопрос", ClientID: &clientID})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled so the job is requeued", err)
+	}
+	if len(runs.finished) != 1 {
+		t.Fatalf("finished runs = %d, want 1", len(runs.finished))
+	}
+	run := runs.finished[0]
+	if run.Outcome != pipeline.OutcomeCancelled || run.FinalMode != "" || run.Error != "" {
+		t.Errorf("run = mode %q outcome %q error %q, want cancelled without mode", run.FinalMode, run.Outcome, run.Error)
+	}
+	if len(repo.replies)+len(repo.notes) != 0 {
+		t.Errorf("interrupted run sent something")
+	}
+}
+
+// chatQueue — очередь одного чата в памяти: Claim берёт всё ждущее одной пачкой,
+// окно тишины не выдерживает
+type chatQueue struct {
+	jobs.Queue
+
+	mu   sync.Mutex
+	jobs []*jobs.Job
+}
+
+func (q *chatQueue) Enqueue(_ context.Context, j *jobs.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j.ID = int64(len(q.jobs) + 1)
+	j.Status = jobs.StatusPending
+	q.jobs = append(q.jobs, j)
+	return nil
+}
+
+func (q *chatQueue) Claim(context.Context) (*jobs.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var head *jobs.Job
+	for _, j := range q.jobs {
+		if j.Status == jobs.StatusRunning {
+			return nil, nil
+		}
+		if j.Status != jobs.StatusPending {
+			continue
+		}
+		j.Status = jobs.StatusRunning
+		j.Attempts++
+		c := *j
+		if head == nil {
+			head = &c
+		} else {
+			head.Batch = append(head.Batch, &c)
+		}
+	}
+	return head, nil
+}
+
+func (q *chatQueue) set(ids []int64, st jobs.Status, lastErr string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, id := range ids {
+		q.jobs[id-1].Status = st
+		if lastErr != "" {
+			q.jobs[id-1].LastError = lastErr
+		}
+	}
+}
+
+func (q *chatQueue) Complete(_ context.Context, id int64) error {
+	q.set([]int64{id}, jobs.StatusDone, "")
+	return nil
+}
+
+func (q *chatQueue) Retry(_ context.Context, id int64, _ time.Time, lastErr string) error {
+	q.set([]int64{id}, jobs.StatusPending, lastErr)
+	return nil
+}
+
+func (q *chatQueue) Fail(_ context.Context, id int64, lastErr string) error {
+	q.set([]int64{id}, jobs.StatusDead, lastErr)
+	return nil
+}
+
+func (q *chatQueue) Superseded(_ context.Context, job *jobs.Job) (bool, error) {
+	ids := job.IDs()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.jobs[ids[len(ids)-1]:] {
+		if j.Status == jobs.StatusPending {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (q *chatQueue) Supersede(_ context.Context, job *jobs.Job) error {
+	q.set(job.IDs(), jobs.StatusPending, "superseded")
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, id := range job.IDs() {
+		q.jobs[id-1].Attempts--
+	}
+	return nil
+}
+
+func (q *chatQueue) RequeueStale(context.Context, time.Duration) (int, error) { return 0, nil }
+
+func (q *chatQueue) done() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.jobs {
+		if j.Status != jobs.StatusDone {
+			return false
+		}
+	}
+	return true
+}
+
+// stallingAI — первый вызов модели висит до отмены, дальше отвечает как обычно
+type stallingAI struct {
+	started chan struct{}
+	once    sync.Once
+}
+
+func (a *stallingAI) GetReply(ctx context.Context, opts ai.Options, system, input string) (ai.Reply, error) {
+	first := false
+	a.once.Do(func() {
+		first = true
+		close(a.started)
+	})
+	if first {
+		<-ctx.Done()
+		return ai.Reply{}, ctx.Err()
+	}
+	return confidentAI{}.GetReply(ctx, opts, system, input)
+}
+
+func TestBurstSupersededMidRunAnswersOnce(t *testing.T) {
+	repo := &fakeRepo{}
+	model := &stallingAI{started: make(chan struct{})}
+	svc := NewService(repo, model, staticCases("CASE_01"), liveTenants{}, nil, nil, nil)
+
+	q := &chatQueue{}
+	enqueue := func(f *Fragment) {
+		b, _ := json.Marshal(f)
+		if err := q.Enqueue(context.Background(), &jobs.Job{Kind: JobKindFragment, Payload: b, Debounce: time.Second}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool := jobs.NewPool(q, NewJobHandler(svc), 1)
+	enqueue(burstFragment("m1"))
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	// модель думает над m1 — клиент дописывает
+	select {
+	case <-model.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline did not start")
+	}
+	enqueue(burstFragment("m2"))
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !q.done() {
+		if time.Now().After(deadline) {
+			t.Fatal("jobs not done")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.messages) != 2 {
+		t.Errorf("saved %d messages, want 2", len(repo.messages))
+	}
+	if len(repo.replies) != 1 {
+		t.Fatalf("replies = %d, want exactly one", len(repo.replies))
+	}
+	if got := repo.replies[0].ReplyTo; got != 2 {
+		t.Errorf("reply to message %d, want the new one (2)", got)
+	}
+}
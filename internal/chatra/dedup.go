@@ -0,0 +1,52 @@
+package chatra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// messageDedupKey — id сообщения Chatra; без него — хэш чата, отправителя, текста
+// и createdAt от Chatra (при повторной доставке он тот же).
+// Нет ни того, ни другого — "": лучше пропустить дубль, чем потерять живое сообщение
+func messageDedupKey(chatID string, m FragmentMessage) string {
+	if m.ID != "" {
+		return "chatra:" + m.ID
+	}
+	if m.CreatedAt <= 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		chatID,
+		m.Type,
+		m.Text,
+		strconv.FormatInt(m.CreatedAt, 10),
+	}, "\x00")))
+	return "hash:" + hex.EncodeToString(sum[:])
+}
+
+// fragmentDedupKey — ключ всей доставки: совпадает, если Chatra прислала тот же фрагмент;
+// "" — хотя бы у одного сообщения нет ключа, фрагмент не дедуплицируется
+func fragmentDedupKey(f *Fragment) string {
+	keys := make([]string, 0, len(f.Messages)+1)
+	keys = append(keys, f.ChatID)
+	for _, m := range f.Messages {
+		key := messageDedupKey(f.ChatID, m)
+		if key == "" {
+			return ""
+		}
+		keys = append(keys, key)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\x00")))
+	return "fragment:" + hex.EncodeToString(sum[:])
+}
+
+func dedupKind(key string) string {
+	if strings.HasPrefix(key, "chatra:") {
+		return "message_chatra_id"
+	}
+	return "message_hash"
+}
@@ -0,0 +1,126 @@
+package chatra
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMessageDedupKey(t *testing.T) {
+	const ms = int64(1_700_000_059_900) // за 100 мс до границы минуты
+
+	tests := []struct {
+		name string
+		a, b FragmentMessage
+		same bool
+	}{
+		{
+			name: "chatra id wins over text",
+			a:    FragmentMessage{ID: "m1", Type: "client", Text: "да", CreatedAt: ms},
+			b:    FragmentMessage{ID: "m1", Type: "client", Text: "да!", CreatedAt: ms + 5000},
+			same: true,
+		},
+		{
+			name: "redelivery without id keeps createdAt",
+			a:    FragmentMessage{Type: "client", Text: "да", CreatedAt: ms},
+			b:    FragmentMessage{Type: "client", Text: "да", CreatedAt: ms},
+			same: true,
+		},
+		{
+			name: "same text a moment later is another message",
+			a:    FragmentMessage{Type: "client", Text: "?", CreatedAt: ms},
+			b:    FragmentMessage{Type: "client", Text: "?", CreatedAt: ms + 200},
+			same: false,
+		},
+		{
+			name: "different sender",
+			a:    FragmentMessage{Type: "client", Text: "да", CreatedAt: ms},
+			b:    FragmentMessage{Type: "agent", Text: "да", CreatedAt: ms},
+			same: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := messageDedupKey("chat", tt.a), messageDedupKey("chat", tt.b)
+			if a == "" || b == "" {
+				t.Fatalf("empty key: %q %q", a, b)
+			}
+			if (a == b) != tt.same {
+				t.Errorf("keys equal = %v, want %v (%q, %q)", a == b, tt.same, a, b)
+			}
+		})
+	}
+}
+
+func TestMessageDedupKeyPrefix(t *testing.T) {
+	tests := []struct {
+		m      FragmentMessage
+		prefix string
+		kind   string
+	}{
+		{FragmentMessage{ID: "abc"}, "chatra:", "message_chatra_id"},
+		{FragmentMessage{Text: "hi", CreatedAt: 1}, "hash:", "message_hash"},
+	}
+	for _, tt := range tests {
+		key := messageDedupKey("chat", tt.m)
+		if !strings.HasPrefix(key, tt.prefix) {
+			t.Errorf("key %q: want prefix %q", key, tt.prefix)
+		}
+		if got := dedupKind(key); got != tt.kind {
+			t.Errorf("dedupKind(%q) = %q, want %q", key, got, tt.kind)
+		}
+	}
+}
+
+func TestMessageDedupKeyWithoutIdentity(t *testing.T) {
+	if key := messageDedupKey("chat", FragmentMessage{Type: "client", Text: "да"}); key != "" {
+		t.Errorf("no id and no createdAt: key = %q, want empty", key)
+	}
+}
+
+func TestFragmentDedupKey(t *testing.T) {
+	withID := FragmentMessage{ID: "m1", Type: "client", Text: "да"}
+	withTS := FragmentMessage{Type: "client", Text: "да", CreatedAt: 1_700_000_000_000}
+	bare := FragmentMessage{Type: "client", Text: "да"}
+
+	tests := []struct {
+		name  string
+		a, b  *Fragment
+		same  bool
+		empty bool
+	}{
+		{
+			name: "redelivery with another receive time",
+			a:    &Fragment{ChatID: "c", Messages: []FragmentMessage{withID, withTS}, ReceivedAt: 100},
+			b:    &Fragment{ChatID: "c", Messages: []FragmentMessage{withID, withTS}, ReceivedAt: 500},
+			same: true,
+		},
+		{
+			name: "other chat",
+			a:    &Fragment{ChatID: "c1", Messages: []FragmentMessage{withTS}},
+			b:    &Fragment{ChatID: "c2", Messages: []FragmentMessage{withTS}},
+			same: false,
+		},
+		{
+			name:  "message without identity disables dedup",
+			a:     &Fragment{ChatID: "c", Messages: []FragmentMessage{withID, bare}},
+			b:     &Fragment{ChatID: "c", Messages: []FragmentMessage{withID, bare}},
+			empty: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := fragmentDedupKey(tt.a), fragmentDedupKey(tt.b)
+			if tt.empty {
+				if a != "" || b != "" {
+					t.Fatalf("want empty keys, got %q %q", a, b)
+				}
+				return
+			}
+			if (a == b) != tt.same {
+				t.Errorf("keys equal = %v, want %v", a == b, tt.same)
+			}
+		})
+	}
+}
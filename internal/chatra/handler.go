@@ -1,43 +1,91 @@
 package chatra
 
 import (
-	"bytes"
-	"context"
 	"encoding/json"
-	"io"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/httpx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/jobs"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/logx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/metrics"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tracing"
 )
 
+// неудачных попыток авторизации с одного IP до блокировки
+const (
+	webhookMaxFailures   = 10
+	webhookFailureWindow = time.Minute
+)
+
+// webhookMaxBody — потолок тела вебхука; фрагмент Chatra — единицы килобайт
+const webhookMaxBody = 1 << 20
+
+var errUnauthorized = errors.New("unauthorized")
+
 type Handler struct {
-	svc Service
+	queue    jobs.Queue
+	tenants  Tenants
+	debounce time.Duration
+	failures *httpx.FailureLimiter
 }
 
-func NewHandler(svc Service) *Handler {
-	return &Handler{svc: svc}
+// debounce — сколько ждать тишины от клиента перед ответом; 0 — не ждать
+func NewHandler(queue jobs.Queue, tenants Tenants, debounce time.Duration) *Handler {
+	return &Handler{
+		queue:    queue,
+		tenants:  tenants,
+		debounce: debounce,
+		failures: httpx.NewFailureLimiter(webhookMaxFailures, webhookFailureWindow),
+	}
 }
 
 func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
-	log.Println("[chatra] webhook hit")
-
-	log.Println("[chatra HEADERS]")
-	for k, v := range r.Header {
-		log.Printf("%s: %v\n", k, v)
+	ctx, span := tracing.Start(r.Context(), "chatra.webhook")
+	defer span.End()
+	if id := tracing.TraceID(ctx); id != "" {
+		ctx = logx.With(ctx, "trace_id", id)
 	}
 
-	body, _ := io.ReadAll(r.Body)
-	log.Printf("[chatra RAW BODY]\n%s\n", body)
+	// Сначала секрет, потом блокировка: за одним адресом (прокси, NAT Chatra)
+	// могут быть и сканер, и настоящая Chatra — верный секрет не блокируется никогда
+	ip := httpx.ClientIP(r)
+	t, err := h.authenticate(r)
+	if errors.Is(err, errUnauthorized) && h.failures.Blocked(ip) {
+		webhookResult(span, "", "blocked")
+		slog.WarnContext(ctx, "webhook blocked", "ip", ip, "path", r.URL.Path, "reason", "too_many_failures")
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, errUnauthorized) {
+		n := h.failures.Fail(ip)
+		webhookResult(span, "", "unauthorized")
+		slog.WarnContext(ctx, "webhook rejected", "ip", ip, "path", r.URL.Path, "reason", err, "failures", n)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "webhook tenant resolve failed", "err", err)
+		webhookResult(span, "", "tenant_error")
+		http.Error(w, "temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
 
-	// вернуть body обратно для Decode
-	r.Body = io.NopCloser(bytes.NewBuffer(body))
+	ctx = logx.With(ctx, "tenant", t.ID)
+	span.SetAttributes(attribute.String("tenant.id", t.ID))
 
 	var payload struct {
-		EventName string `json:"eventName"`
-		Messages  []struct {
-			Type string `json:"type"`
-			Text string `json:"text"`
-		} `json:"messages"`
-		Client struct {
+		EventName string            `json:"eventName"`
+		Messages  []FragmentMessage `json:"messages"`
+		Client    struct {
 			ChatID string         `json:"chatId"`
 			ID     string         `json:"id"`
 			Info   map[string]any `json:"info"`
@@ -45,79 +93,104 @@ func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		} `json:"client"`
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, webhookMaxBody)
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		log.Println("[chatra] decode error:", err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			slog.WarnContext(ctx, "webhook body too large", "limit", tooLarge.Limit)
+			webhookResult(span, "", "too_large")
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		slog.WarnContext(ctx, "webhook decode failed", "err", err)
+		webhookResult(span, "", "invalid_json")
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf(
-		"[chatra] event=%s chatId=%s clientId=%s messages=%d",
-		payload.EventName,
-		payload.Client.ChatID,
-		payload.Client.ID,
-		len(payload.Messages),
+	ctx = logx.With(ctx, "chat_id", payload.Client.ChatID)
+	span.SetAttributes(attribute.String("chat.id", payload.Client.ChatID))
+	slog.InfoContext(ctx, "webhook received",
+		"event", payload.EventName,
+		"client_id", payload.Client.ID,
+		"messages", len(payload.Messages),
 	)
 
-	// ACK СРАЗУ
+	if payload.EventName != "chatFragment" {
+		slog.InfoContext(ctx, "webhook skipped", "event", payload.EventName)
+		webhookResult(span, payload.EventName, "skipped")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+		return
+	}
+
+	// СНАЧАЛА В ОЧЕРЕДЬ, ПОТОМ ACK — иначе рестарт теряет сообщения
+	err = h.enqueue(ctx, &Fragment{
+		RequestID:         logx.RequestID(ctx),
+		TraceParent:       tracing.Inject(ctx),
+		TenantID:          t.ID,
+		ChatID:            payload.Client.ChatID,
+		ClientID:          payload.Client.ID,
+		ClientInfo:        payload.Client.Info,
+		ClientIntegration: payload.Client.Int,
+		Messages:          payload.Messages,
+		ReceivedAt:        time.Now().Unix(),
+	})
+	switch {
+	case errors.Is(err, jobs.ErrDuplicate):
+		// Chatra повторила доставку — отвечаем ok, чтобы больше не слала
+		metrics.Dedup.WithLabelValues("webhook_fragment").Inc()
+		webhookResult(span, payload.EventName, "duplicate")
+		slog.InfoContext(ctx, "duplicate fragment skipped")
+	case err != nil:
+		slog.ErrorContext(ctx, "fragment enqueue failed", "err", err)
+		webhookResult(span, payload.EventName, "enqueue_error")
+		http.Error(w, "temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	default:
+		webhookResult(span, payload.EventName, "queued")
+	}
+
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("ok"))
+}
 
-	if payload.EventName != "chatFragment" {
-		log.Println("[chatra] skip non chatFragment")
-		return
+// webhookResult — счётчик вебхуков и итог в спане; event == "" — тело ещё не разобрано
+func webhookResult(span trace.Span, event, result string) {
+	if event == "" {
+		event = "unknown"
 	}
+	metrics.WebhookRequests.WithLabelValues(event, result).Inc()
+	span.SetAttributes(attribute.String("chatra.event", event), attribute.String("webhook.result", result))
+}
 
-	p := payload
-
-	// ВСЯ ОБРАБОТКА — В ФОНЕ
-	// ВСЯ ОБРАБОТКА — В ФОНЕ
-	go func() {
-		ctx := context.Background()
-
-		for i, m := range p.Messages {
-			log.Printf("[chatra] msg[%d] type=%s text=%q", i, m.Type, m.Text)
-
-			if m.Text == "" {
-				continue
-			}
-
-			switch m.Type {
-
-			case "client":
-				msg := &Message{
-					ChatID:            p.Client.ChatID,
-					Sender:            SenderClient,
-					Text:              m.Text,
-					ClientID:          &p.Client.ID,
-					ClientInfo:        p.Client.Info,
-					ClientIntegration: p.Client.Int,
-				}
-
-				log.Println("[chatra] -> HandleIncoming start")
-
-				if err := h.svc.HandleIncoming(ctx, msg); err != nil {
-					log.Println("[chatra] HandleIncoming error:", err)
-				}
-
-				log.Println("[chatra] -> HandleIncoming done")
-
-			case "agent":
-				if err := h.svc.SaveOnly(ctx, &Message{
-					ChatID:   p.Client.ChatID,
-					Sender:   SenderSupporter,
-					Text:     m.Text,
-					ClientID: &p.Client.ID,
-				}); err != nil {
-					log.Println("[chatra] Save agent message error:", err)
-				}
-
-			case "system":
-				// игнорируем системные сообщения
-				continue
-			}
+// authenticate — X-Webhook-Secret обязателен; тенант по пути /chatra/webhook/{tenant}
+// или по самому секрету. Ошибки доступа оборачивают errUnauthorized.
+func (h *Handler) authenticate(r *http.Request) (*tenant.Tenant, error) {
+	ctx := r.Context()
+
+	secret := r.Header.Get("X-Webhook-Secret")
+	if secret == "" {
+		return nil, fmt.Errorf("%w: missing secret", errUnauthorized)
+	}
+
+	if id := chi.URLParam(r, "tenant"); id != "" {
+		t, err := h.tenants.Get(ctx, id)
+		if errors.Is(err, tenant.ErrNotFound) {
+			return nil, fmt.Errorf("%w: unknown tenant %q", errUnauthorized, id)
+		}
+		if err != nil {
+			return nil, err
 		}
-	}()
+		if !t.CheckSecret(secret) {
+			return nil, fmt.Errorf("%w: bad secret for tenant %q", errUnauthorized, id)
+		}
+		return t, nil
+	}
 
-	log.Println("[chatra] webhook ACK sent")
+	t, err := h.tenants.BySecret(ctx, secret)
+	if errors.Is(err, tenant.ErrNotFound) {
+		return nil, fmt.Errorf("%w: bad secret", errUnauthorized)
+	}
+	return t, err
 }
@@ -0,0 +1,102 @@
+package chatra
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/jobs"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+)
+
+// memQueue — принятые задачи в памяти
+type memQueue struct {
+	jobs.Queue
+	queued []*jobs.Job
+}
+
+func (q *memQueue) Enqueue(_ context.Context, j *jobs.Job) error {
+	q.queued = append(q.queued, j)
+	return nil
+}
+
+// secretTenants — один тенант с секретом "good"
+type secretTenants struct{}
+
+func (secretTenants) Get(_ context.Context, id string) (*tenant.Tenant, error) {
+	return nil, tenant.ErrNotFound
+}
+
+func (secretTenants) BySecret(_ context.Context, secret string) (*tenant.Tenant, error) {
+	t := &tenant.Tenant{ID: "t", WebhookSecrets: []string{"good"}}
+	if !t.CheckSecret(secret) {
+		return nil, tenant.ErrNotFound
+	}
+	return t, nil
+}
+
+const fragmentBody = `{"eventName":"chatFragment","client":{"chatId":"chat","id":"client"},"messages":[{"id":"m1","type":"client","text":"hi"}]}`
+
+func webhook(h *Handler, secret, body string) int {
+	r := httptest.NewRequest(http.MethodPost, "/chatra/webhook", strings.NewReader(body))
+	r.RemoteAddr = "10.0.0.1:5000"
+	if secret != "" {
+		r.Header.Set("X-Webhook-Secret", secret)
+	}
+	w := httptest.NewRecorder()
+	h.HandleWebhook(w, r)
+	return w.Code
+}
+
+func TestHandleWebhookLockout(t *testing.T) {
+	q := &memQueue{}
+	h := NewHandler(q, secretTenants{}, 0)
+
+	for i := 0; i < webhookMaxFailures; i++ {
+		if code := webhook(h, "bad", fragmentBody); code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d, want 401", i+1, code)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		want   int
+	}{
+		{"bad secret from blocked address", "bad", http.StatusTooManyRequests},
+		{"missing secret from blocked address", "", http.StatusTooManyRequests},
+		{"valid secret from the same address passes", "good", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := webhook(h, tt.secret, fragmentBody); code != tt.want {
+				t.Errorf("status %d, want %d", code, tt.want)
+			}
+		})
+	}
+	if len(q.queued) != 1 {
+		t.Errorf("queued %d fragments, want 1", len(q.queued))
+	}
+}
+
+func TestHandleWebhookBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"fragment", fragmentBody, http.StatusOK},
+		{"invalid json", `{`, http.StatusBadRequest},
+		{"too large", `{"eventName":"` + strings.Repeat("x", webhookMaxBody) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(&memQueue{}, secretTenants{}, 0)
+			if code := webhook(h, "good", tt.body); code != tt.want {
+				t.Errorf("status %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
@@ -3,37 +3,62 @@ package chatra
 import (
 	"context"
 	"database/sql"
+	"errors"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/logx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/outbox"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tracing"
 )
 
 type repo struct {
-	db *sql.DB
+	db     *sql.DB
+	outbox outbox.Store
 }
 
-func NewRepo(db *sql.DB) Repo {
-	return &repo{db: db}
+func NewRepo(db *sql.DB, ob outbox.Store) Repo {
+	return &repo{db: db, outbox: ob}
 }
 
 func (r *repo) SaveMessage(ctx context.Context, msg *Message) error {
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO messages (chat_id, sender, text, client_id, supporter_id)
-		VALUES ($1, $2, $3, $4, $5)
+	var dedupKey *string
+	if msg.DedupKey != "" {
+		dedupKey = &msg.DedupKey
+	}
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO messages (tenant_id, chat_id, sender, text, client_id, supporter_id, dedup_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (tenant_id, dedup_key) WHERE dedup_key IS NOT NULL DO NOTHING
+		RETURNING id
 	`,
+		msg.TenantID,
 		msg.ChatID,
 		string(msg.Sender),
 		msg.Text,
 		msg.ClientID,
 		msg.SupporterID,
-	)
-	return err
+		dedupKey,
+	).Scan(&msg.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if err := r.db.QueryRowContext(ctx, `
+		SELECT id FROM messages WHERE tenant_id = $1 AND dedup_key = $2
+	`, msg.TenantID, msg.DedupKey).Scan(&msg.ID); err != nil {
+		return err
+	}
+	return ErrDuplicate
 }
 
-func (r *repo) GetHistory(ctx context.Context, chatID string) ([]Message, error) {
+func (r *repo) GetHistory(ctx context.Context, tenantID string, chatID string) ([]Message, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, chat_id, sender, text, client_id, supporter_id, extract(epoch from created_at)::bigint
+		SELECT id, tenant_id, chat_id, sender, text, client_id, supporter_id, extract(epoch from created_at)::bigint,
+		       COALESCE(delivery_status, '')
 		FROM messages
-		WHERE chat_id = $1
+		WHERE tenant_id = $1 AND chat_id = $2
 		ORDER BY created_at ASC
-	`, chatID)
+	`, tenantID, chatID)
 	if err != nil {
 		return nil, err
 	}
@@ -45,12 +70,14 @@ func (r *repo) GetHistory(ctx context.Context, chatID string) ([]Message, error)
 		var sender string
 		if err := rows.Scan(
 			&m.ID,
+			&m.TenantID,
 			&m.ChatID,
 			&sender,
 			&m.Text,
 			&m.ClientID,
 			&m.SupporterID,
 			&m.CreatedAt,
+			&m.DeliveryStatus,
 		); err != nil {
 			return nil, err
 		}
@@ -60,3 +87,94 @@ func (r *repo) GetHistory(ctx context.Context, chatID string) ([]Message, error)
 
 	return out, rows.Err()
 }
+
+func (r *repo) SaveCaseRevisions(ctx context.Context, messageID int64, revisions map[string]int64) error {
+	for caseID, revID := range revisions {
+		if _, err := r.db.ExecContext(ctx, `
+			INSERT INTO message_case_revisions (message_id, case_id, revision_id)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (message_id, case_id) DO NOTHING
+		`, messageID, caseID, revID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repo) SaveReply(ctx context.Context, msg *Message) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var replyTo *int64
+	if msg.ReplyTo != 0 {
+		replyTo = &msg.ReplyTo
+	}
+
+	msg.DeliveryStatus = string(outbox.StatusPending)
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO messages (tenant_id, chat_id, sender, text, client_id, supporter_id, delivery_status, reply_to)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`,
+		msg.TenantID,
+		msg.ChatID,
+		string(msg.Sender),
+		msg.Text,
+		msg.ClientID,
+		msg.SupporterID,
+		msg.DeliveryStatus,
+		replyTo,
+	).Scan(&msg.ID); err != nil {
+		return err
+	}
+
+	if err := r.outbox.AddTx(ctx, tx, &outbox.Item{
+		TenantID:    msg.TenantID,
+		ChatID:      msg.ChatID,
+		ClientID:    *msg.ClientID,
+		MessageID:   msg.ID,
+		ReplyTo:     msg.ReplyTo,
+		Kind:        outbox.KindMessage,
+		Text:        msg.Text,
+		RequestID:   logx.RequestID(ctx),
+		TraceParent: tracing.Inject(ctx),
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	r.outbox.Notify()
+	return nil
+}
+
+func (r *repo) QueueNote(ctx context.Context, tenantID, chatID, clientID string, replyTo int64, text string) error {
+	return r.outbox.Add(ctx, &outbox.Item{
+		TenantID:    tenantID,
+		ChatID:      chatID,
+		ClientID:    clientID,
+		ReplyTo:     replyTo,
+		Kind:        outbox.KindNote,
+		Text:        text,
+		RequestID:   logx.RequestID(ctx),
+		TraceParent: tracing.Inject(ctx),
+	})
+}
+
+func (r *repo) Answered(ctx context.Context, messageID int64) (bool, error) {
+	var ok bool
+	err := r.db.QueryRowContext(ctx, `
+		WITH q AS (
+			SELECT c.id FROM messages m
+			JOIN messages c ON c.tenant_id = m.tenant_id AND c.chat_id = m.chat_id AND c.id >= m.id
+			WHERE m.id = $1
+		)
+		SELECT EXISTS (SELECT 1 FROM messages a JOIN q ON a.reply_to = q.id)
+		    OR EXISTS (SELECT 1 FROM outbox o JOIN q ON o.reply_to = q.id)
+	`, messageID).Scan(&ok)
+	return ok, err
+}
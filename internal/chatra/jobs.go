@@ -0,0 +1,74 @@
+package chatra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/jobs"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/logx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tracing"
+)
+
+const JobKindFragment = "chatra.fragment"
+
+// enqueue — jobs.ErrDuplicate, если такой фрагмент уже принимали
+func (h *Handler) enqueue(ctx context.Context, f *Fragment) error {
+	payload, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return h.queue.Enqueue(ctx, &jobs.Job{
+		TenantID: f.TenantID,
+		ChatID:   f.ChatID,
+		Kind:     JobKindFragment,
+		Payload:  payload,
+		DedupKey: fragmentDedupKey(f),
+		Debounce: h.debounce,
+	})
+}
+
+// NewJobHandler — обработчик задач очереди для воркеров
+func NewJobHandler(svc Service) jobs.HandlerFunc {
+	return func(ctx context.Context, job *jobs.Job) error {
+		switch job.Kind {
+		case JobKindFragment:
+			fs := make([]*Fragment, 0, len(job.Batch)+1)
+			for _, j := range append([]*jobs.Job{job}, job.Batch...) {
+				var f Fragment
+				if err := json.Unmarshal(j.Payload, &f); err != nil {
+					return fmt.Errorf("%w: decode fragment %d: %v", jobs.ErrPermanent, j.ID, err)
+				}
+				f.Retry = j.Retried()
+				fs = append(fs, &f)
+			}
+			// id запроса и трасса — последнего фрагмента: на него и строится ответ
+			f := fs[len(fs)-1]
+			ctx = logx.WithRequestID(ctx, f.RequestID)
+			ctx = logx.With(ctx, "tenant", f.TenantID, "chat_id", f.ChatID)
+
+			ctx, span := tracing.Start(tracing.Extract(ctx, f.TraceParent), "chatra.fragment",
+				attribute.String("tenant.id", f.TenantID),
+				attribute.String("chat.id", f.ChatID),
+				attribute.Int("job.attempt", job.Attempts),
+				attribute.Int("job.batch", len(fs)),
+			)
+			if id := tracing.TraceID(ctx); id != "" {
+				ctx = logx.With(ctx, "trace_id", id)
+			}
+
+			var err error
+			if job.Debounce > 0 {
+				err = svc.HandleBurst(ctx, fs)
+			} else {
+				err = svc.HandleFragment(ctx, f)
+			}
+			tracing.End(span, err)
+			return err
+		default:
+			return fmt.Errorf("%w: unknown job kind %q", jobs.ErrPermanent, job.Kind)
+		}
+	}
+}
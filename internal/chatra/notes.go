@@ -0,0 +1,89 @@
+package chatra
+
+import (
+	"strings"
+	"time"
+)
+
+// Раздел AI в заметках клиента. Всё вне маркеров — текст операторов, его не трогаем.
+const (
+	aiNotesBegin = "===== AI-ассистент (автоматически, не редактируйте внутри) ====="
+	aiNotesEnd   = "===== конец раздела AI ====="
+	aiEntryMark  = "[AI @ "
+
+	aiNotesMaxEntries = 3    // сколько последних записей AI хранить
+	aiNotesMaxChars   = 4000 // потолок раздела AI в символах
+)
+
+// mergeNotes — заметки с новой записью AI: человеческий текст как был,
+// раздел AI в конце, старые записи AI отрезаются по количеству и размеру
+func mergeNotes(existing, entry string, now time.Time) string {
+	human, entries := splitNotes(existing)
+
+	entry = aiEntryMark + now.UTC().Format("2006-01-02 15:04 UTC") + "]\n" + strings.TrimSpace(entry)
+	entries = append(entries, truncateRunes(entry, aiNotesMaxChars))
+
+	if len(entries) > aiNotesMaxEntries {
+		entries = entries[len(entries)-aiNotesMaxEntries:]
+	}
+	for len(entries) > 1 && runeLen(strings.Join(entries, "\n\n")) > aiNotesMaxChars {
+		entries = entries[1:]
+	}
+
+	section := aiNotesBegin + "\n" + strings.Join(entries, "\n\n") + "\n" + aiNotesEnd
+	if human == "" {
+		return section
+	}
+	return human + "\n\n" + section
+}
+
+// splitNotes — текст операторов и записи AI по отдельности.
+// Разделом AI считается только последний закрытый раздел и только записи с
+// aiEntryMark; всё остальное (раздел без конца, текст внутри раздела без
+// метки) — человеческий текст, его нельзя потерять при обрезке записей
+func splitNotes(notes string) (human string, entries []string) {
+	start := strings.LastIndex(notes, aiNotesBegin)
+	if start < 0 {
+		return strings.TrimSpace(notes), nil
+	}
+
+	body, after, closed := strings.Cut(notes[start+len(aiNotesBegin):], aiNotesEnd)
+	if !closed {
+		// маркер конца стёрли руками — не знаем, где кончается AI, ничего не трогаем
+		return strings.TrimSpace(notes), nil
+	}
+
+	chunks := strings.Split("\n"+body, "\n"+aiEntryMark)
+	for _, chunk := range chunks[1:] {
+		if chunk = strings.TrimSpace(chunk); chunk != "" {
+			entries = append(entries, aiEntryMark+chunk)
+		}
+	}
+
+	// chunks[0] — текст раздела до первой записи: дописан человеком
+	human = joinNonEmpty(notes[:start], chunks[0], after)
+	return human, entries
+}
+
+// joinNonEmpty — непустые части через пустую строку
+func joinNonEmpty(parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
+
+func runeLen(s string) int {
+	return len([]rune(s))
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
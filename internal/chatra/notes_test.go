@@ -0,0 +1,130 @@
+package chatra
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func section(entries ...string) string {
+	return aiNotesBegin + "\n" + strings.Join(entries, "\n\n") + "\n" + aiNotesEnd
+}
+
+func TestSplitNotes(t *testing.T) {
+	e1 := aiEntryMark + "2026-01-01 10:00 UTC]\nпервая"
+	e2 := aiEntryMark + "2026-01-02 10:00 UTC]\nвторая"
+
+	tests := []struct {
+		name        string
+		notes       string
+		wantHuman   string
+		wantEntries []string
+	}{
+		{"empty", "", "", nil},
+		{"only human", "  VIP клиент \n", "VIP клиент", nil},
+		{"only section", section(e1, e2), "", []string{e1, e2}},
+		{
+			"human around section",
+			"до\n\n" + section(e1) + "\n\nпосле",
+			"до\n\nпосле",
+			[]string{e1},
+		},
+		{
+			"unclosed section stays human",
+			"до\n\n" + aiNotesBegin + "\n" + e1 + "\nдописал оператор",
+			"до\n\n" + aiNotesBegin + "\n" + e1 + "\nдописал оператор",
+			nil,
+		},
+		{
+			"text before first entry is human",
+			"до\n\n" + aiNotesBegin + "\nзаметка оператора\n" + e1 + "\n" + aiNotesEnd,
+			"до\n\nзаметка оператора",
+			[]string{e1},
+		},
+		{
+			"section without entries is human",
+			aiNotesBegin + "\nзаметка оператора\n" + aiNotesEnd,
+			"заметка оператора",
+			nil,
+		},
+		{
+			"last closed section wins",
+			"до\n" + aiNotesBegin + "\nстарое\n\n" + section(e2),
+			"до\n" + aiNotesBegin + "\nстарое",
+			[]string{e2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			human, entries := splitNotes(tt.notes)
+			if human != tt.wantHuman {
+				t.Errorf("human = %q, want %q", human, tt.wantHuman)
+			}
+			if !reflect.DeepEqual(entries, tt.wantEntries) {
+				t.Errorf("entries = %q, want %q", entries, tt.wantEntries)
+			}
+		})
+	}
+}
+
+func TestMergeNotes(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry := func(text string) string {
+		return aiEntryMark + "2026-03-01 12:00 UTC]\n" + text
+	}
+	old := func(i int) string {
+		return fmt.Sprintf("%s2026-01-0%d 10:00 UTC]\nзапись", aiEntryMark, i+1)
+	}
+
+	tests := []struct {
+		name     string
+		existing string
+		entry    string
+		want     string
+	}{
+		{"empty notes", "", "новая", section(entry("новая"))},
+		{"human kept first", "VIP", "новая", "VIP\n\n" + section(entry("новая"))},
+		{
+			"oldest entries dropped",
+			section(old(0), old(1), old(2)),
+			"новая",
+			section(old(1), old(2), entry("новая")),
+		},
+		{
+			"unclosed section is not eaten",
+			aiNotesBegin + "\nчей-то текст",
+			"новая",
+			aiNotesBegin + "\nчей-то текст\n\n" + section(entry("новая")),
+		},
+		{
+			"stray text in section survives trimming",
+			aiNotesBegin + "\nтекст оператора\n" + old(0) + "\n\n" + old(1) + "\n\n" + old(2) + "\n" + aiNotesEnd,
+			"новая",
+			"текст оператора\n\n" + section(old(1), old(2), entry("новая")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeNotes(tt.existing, tt.entry, now); got != tt.want {
+				t.Errorf("mergeNotes =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeNotesSizeCap(t *testing.T) {
+	big := strings.Repeat("я", aiNotesMaxChars)
+	got := mergeNotes("VIP", big, time.Now())
+
+	human, entries := splitNotes(got)
+	if human != "VIP" {
+		t.Errorf("human = %q, want VIP", human)
+	}
+	if len(entries) != 1 || runeLen(entries[0]) > aiNotesMaxChars {
+		t.Errorf("entries = %d, first %d runes, want one within %d", len(entries), runeLen(entries[0]), aiNotesMaxChars)
+	}
+}
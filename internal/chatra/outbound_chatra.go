@@ -4,40 +4,126 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/httpx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/metrics"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tracing"
 )
 
+// DefaultChatraBaseURL — REST API Chatra, если CHATRA_API_BASE_URL не задан
+const DefaultChatraBaseURL = "https://app.chatra.io/api"
+
+// ChatraConfig — подключение к API Chatra, общее для всех тенантов
+type ChatraConfig struct {
+	BaseURL string
+	Timeout time.Duration // на одну попытку
+	// Transport — nil: http.DefaultTransport; в тестах — заглушка (см. chatratest)
+	Transport http.RoundTripper
+
+	Retry RetryPolicy
+	// Breaker — общий на все тенанты: лежит Chatra целиком; nil — свой на клиента
+	Breaker *httpx.Breaker
+}
+
+// ChatraConfigFromEnv — CHATRA_API_BASE_URL, CHATRA_API_TIMEOUT_SECONDS,
+// CHATRA_RETRY_ATTEMPTS, CHATRA_BREAKER_FAILURES, CHATRA_BREAKER_COOLDOWN_SECONDS
+func ChatraConfigFromEnv() ChatraConfig {
+	return ChatraConfig{
+		BaseURL: os.Getenv("CHATRA_API_BASE_URL"),
+		Timeout: time.Duration(envPositive("CHATRA_API_TIMEOUT_SECONDS", 10)) * time.Second,
+		Retry: RetryPolicy{
+			MaxAttempts: envPositive("CHATRA_RETRY_ATTEMPTS", 3),
+		},
+		Breaker: httpx.NewBreaker(
+			envPositive("CHATRA_BREAKER_FAILURES", 5),
+			time.Duration(envPositive("CHATRA_BREAKER_COOLDOWN_SECONDS", 30))*time.Second,
+		),
+	}
+}
+
+func envPositive(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
 type ChatraOutbound struct {
 	baseURL   string
 	publicKey string
 	secretKey string
 	client    *http.Client
+	retry     RetryPolicy
+	breaker   *httpx.Breaker
 }
 
-func NewChatraOutbound() *ChatraOutbound {
-	secret := strings.TrimSpace(os.Getenv("CHATRA_API_TOKEN"))
-	if secret == "" {
-		panic("CHATRA_API_TOKEN not set (expected SECRET key)")
+func NewChatraOutbound(cfg ChatraConfig, publicKey, secretKey string) *ChatraOutbound {
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultChatraBaseURL
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	breaker := cfg.Breaker
+	if breaker == nil {
+		breaker = httpx.NewBreaker(5, 30*time.Second)
 	}
 
 	return &ChatraOutbound{
-		baseURL:   "https://app.chatra.io/api",
-		publicKey: "KQN2vdXYigrbe3F36", // PUBLIC key (ChatraID)
-		secretKey: secret,              // SECRET key
-		client:    &http.Client{Timeout: 10 * time.Second},
+		baseURL:   baseURL,
+		publicKey: publicKey, // PUBLIC key (ChatraID)
+		secretKey: secretKey, // SECRET key
+		client:    &http.Client{Timeout: timeout, Transport: cfg.Transport},
+		retry:     cfg.Retry.withDefaults(),
+		breaker:   breaker,
 	}
 }
 
+// ChatraOutbounds — по клиенту на тенанта; пересоздаётся при смене ключей
+type ChatraOutbounds struct {
+	cfg ChatraConfig
+
+	mu       sync.Mutex
+	byTenant map[string]*ChatraOutbound
+}
+
+func NewChatraOutbounds(cfg ChatraConfig) *ChatraOutbounds {
+	if cfg.Breaker == nil {
+		cfg.Breaker = httpx.NewBreaker(5, 30*time.Second)
+	}
+	return &ChatraOutbounds{cfg: cfg, byTenant: map[string]*ChatraOutbound{}}
+}
+
+func (f *ChatraOutbounds) For(t *tenant.Tenant) Outbound {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.byTenant[t.ID]
+	if !ok || c.publicKey != t.ChatraPublicKey || c.secretKey != t.ChatraSecretKey {
+		c = NewChatraOutbound(f.cfg, t.ChatraPublicKey, t.ChatraSecretKey)
+		f.byTenant[t.ID] = c
+	}
+	return c
+}
+
 // ---------- PUBLIC API ----------
 
-// Отправка сообщения клиенту (видит клиент)
-func (c *ChatraOutbound) SendToChhat(ctx context.Context, clientID, text string) error {
+// SendToChat — сообщение клиенту от имени бота (видит клиент)
+func (c *ChatraOutbound) SendToChat(ctx context.Context, clientID, text string) error {
 	return c.send(
 		ctx,
 		http.MethodPost,
@@ -49,20 +135,25 @@ func (c *ChatraOutbound) SendToChhat(ctx context.Context, clientID, text string)
 	)
 }
 
-func (c ChatraOutbound) SendToChat(ctx context.Context, clientID string, text string) error {
-	log.Println("[SAFE MODE] BLOCKED SendToChat:", clientID, text)
-	return nil
-}
-
-// SendNote — заметка для оператора (client info panel), клиент НЕ видит
-// Реально поддержано Chatra через PUT /clients/:id → notes
+// SendNote — заметка для оператора (client info panel), клиент НЕ видит.
+// Chatra умеет только PUT /clients/:id → notes целиком, поэтому текущие заметки
+// сначала читаются, и AI-запись вливается в свой раздел, не трогая текст операторов.
+// Не смогли прочитать — не пишем вовсе: лучше потерять заметку, чем чужой текст.
 func (c *ChatraOutbound) SendNote(ctx context.Context, clientID, text string) error {
+	var client struct {
+		Notes string `json:"notes"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/clients/"+clientID, nil, &client); err != nil {
+		slog.ErrorContext(ctx, "note not written, client notes unavailable", "client_id", clientID, "note_len", len(text), "err", err)
+		return err
+	}
+
 	return c.send(
 		ctx,
 		http.MethodPut,
 		"/clients/"+clientID,
 		map[string]any{
-			"notes": text,
+			"notes": mergeNotes(client.Notes, text, time.Now()),
 		},
 	)
 }
@@ -75,16 +166,92 @@ func (c *ChatraOutbound) send(
 	path string,
 	body any,
 ) error {
-	b, err := json.Marshal(body)
-	if err != nil {
-		return err
+	return c.do(ctx, method, path, body, nil)
+}
+
+// do — запрос к API с повторами; body == nil — без тела, out != nil — разобрать ответ.
+// Спан — на весь запрос вместе с повторами.
+func (c *ChatraOutbound) do(
+	ctx context.Context,
+	method string,
+	path string,
+	body any,
+	out any,
+) (err error) {
+	ctx, span := tracing.Start(ctx, method+" "+endpoint(path),
+		attribute.String("http.request.method", method),
+		attribute.String("url.template", endpoint(path)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	var payload []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = b
+	}
+
+	for attempt := 1; ; attempt++ {
+		if !c.breaker.Allow() {
+			metrics.ChatraRequests.WithLabelValues(method, endpoint(path), "circuit_open").Inc()
+			return ErrCircuitOpen
+		}
+
+		span.SetAttributes(attribute.Int("chatra.attempts", attempt))
+		err := c.attempt(ctx, method, path, payload, out)
+		if err == nil {
+			c.breaker.Success()
+			return nil
+		}
+
+		if serverFault(err) {
+			c.breaker.Failure()
+		} else if ctx.Err() == nil {
+			// Chatra ответила — значит жива, даже если запрос наш кривой
+			c.breaker.Success()
+		} else {
+			// отменили сами — иначе отменённая проба держит breaker открытым навсегда
+			c.breaker.Release()
+		}
+
+		if !Retryable(err) || !retrySafe(method, err) || attempt >= c.retry.MaxAttempts {
+			return err
+		}
+
+		delay := c.retry.delay(attempt, err)
+		if dl, ok := ctx.Deadline(); ok && time.Until(dl) < delay {
+			return err
+		}
+
+		slog.WarnContext(ctx, "chatra request failed, retrying",
+			"method", method, "endpoint", endpoint(path), "attempt", attempt, "delay", delay.String(), "err", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *ChatraOutbound) attempt(
+	ctx context.Context,
+	method string,
+	path string,
+	payload []byte,
+	out any,
+) error {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
 	}
 
 	req, err := http.NewRequestWithContext(
 		ctx,
 		method,
 		c.baseURL+path,
-		bytes.NewReader(b),
+		reqBody,
 	)
 	if err != nil {
 		return err
@@ -95,23 +262,39 @@ func (c *ChatraOutbound) send(
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", auth)
 
-	log.Println("[chatra] METHOD =", method)
-	log.Println("[chatra] URL    =", c.baseURL+path)
+	slog.DebugContext(ctx, "chatra request", "method", method, "endpoint", endpoint(path))
 
+	started := time.Now()
 	resp, err := c.client.Do(req)
+	metrics.ChatraDuration.WithLabelValues(method, endpoint(path)).Observe(time.Since(started).Seconds())
 	if err != nil {
+		metrics.ChatraRequests.WithLabelValues(method, endpoint(path), "error").Inc()
 		return err
 	}
 	defer resp.Body.Close()
+	metrics.ChatraRequests.WithLabelValues(method, endpoint(path), strconv.Itoa(resp.StatusCode)).Inc()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	if resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return errors.New(
-			"chatra api error: " +
-				resp.Status +
-				" body=" + string(respBody),
-		)
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return &APIError{
+			Status:     resp.StatusCode,
+			StatusText: resp.Status,
+			Body:       string(respBody),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
 	return nil
 }
+
+// endpoint — путь без id клиента, чтобы метки не плодились
+func endpoint(path string) string {
+	if strings.HasPrefix(path, "/clients/") {
+		return "/clients/:id"
+	}
+	return path
+}
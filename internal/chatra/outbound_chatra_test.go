@@ -0,0 +1,128 @@
+package chatra_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/chatra"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/chatra/chatratest"
+)
+
+const wantAuth = "Chatra.Simple pub:sec"
+
+// newOutbound — клиент к заглушке с короткими паузами между повторами
+func newOutbound(srv *chatratest.Server) *chatra.ChatraOutbound {
+	cfg := srv.Config()
+	cfg.Retry = chatra.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	return chatra.NewChatraOutbound(cfg, "pub", "sec")
+}
+
+func TestOutboundSendToChat(t *testing.T) {
+	srv := chatratest.NewServer()
+	defer srv.Close()
+
+	if err := newOutbound(srv).SendToChat(context.Background(), "client1", "привет"); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("requests = %d, want 1", len(reqs))
+	}
+	r := reqs[0]
+	if r.Method != http.MethodPost || r.Path != "/pushedMessages" {
+		t.Errorf("request = %s %s, want POST /pushedMessages", r.Method, r.Path)
+	}
+	if r.Auth != wantAuth {
+		t.Errorf("Authorization = %q, want %q", r.Auth, wantAuth)
+	}
+	body := r.JSON()
+	if body["clientId"] != "client1" || body["text"] != "привет" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestOutboundSendNote(t *testing.T) {
+	srv := chatratest.NewServer()
+	defer srv.Close()
+	srv.SetNotes("client1", "VIP, звонить после 18:00")
+
+	if err := newOutbound(srv).SendNote(context.Background(), "client1", "клиент спрашивал про VPN"); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("requests = %d, want GET and PUT", len(reqs))
+	}
+	for i, want := range []string{http.MethodGet, http.MethodPut} {
+		if reqs[i].Method != want || reqs[i].Path != "/clients/client1" {
+			t.Errorf("request %d = %s %s, want %s /clients/client1", i, reqs[i].Method, reqs[i].Path, want)
+		}
+		if reqs[i].Auth != wantAuth {
+			t.Errorf("request %d: Authorization = %q", i, reqs[i].Auth)
+		}
+	}
+
+	notes := srv.Notes("client1")
+	if !strings.HasPrefix(notes, "VIP, звонить после 18:00") {
+		t.Errorf("operator notes lost: %q", notes)
+	}
+	if !strings.Contains(notes, "клиент спрашивал про VPN") {
+		t.Errorf("AI entry missing: %q", notes)
+	}
+}
+
+func TestOutboundSendNoteReadFailureWritesNothing(t *testing.T) {
+	srv := chatratest.NewServer()
+	defer srv.Close()
+	srv.SetNotes("client1", "текст оператора")
+	srv.On(http.MethodGet, "/clients/client1", chatratest.Response{Status: http.StatusBadRequest, Body: `{"error":"bad"}`})
+
+	if err := newOutbound(srv).SendNote(context.Background(), "client1", "запись"); err == nil {
+		t.Fatal("want error when notes can't be read")
+	}
+	for _, r := range srv.Requests() {
+		if r.Method == http.MethodPut {
+			t.Fatal("notes written without reading them first")
+		}
+	}
+	if got := srv.Notes("client1"); got != "текст оператора" {
+		t.Errorf("notes = %q, want untouched", got)
+	}
+}
+
+func TestOutboundRetriesUnavailable(t *testing.T) {
+	srv := chatratest.NewServer()
+	defer srv.Close()
+	srv.On(http.MethodPost, "/pushedMessages",
+		chatratest.Response{Status: http.StatusServiceUnavailable},
+		chatratest.Response{Body: `{"ok":true}`},
+	)
+
+	if err := newOutbound(srv).SendToChat(context.Background(), "client1", "привет"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Requests()); n != 2 {
+		t.Errorf("requests = %d, want 503 then success", n)
+	}
+}
+
+func TestOutboundInjectedTransport(t *testing.T) {
+	srv := chatratest.NewServer()
+	defer srv.Close()
+
+	// base URL по умолчанию — запрос уходит в заглушку только через транспорт
+	out := chatra.NewChatraOutbound(chatra.ChatraConfig{Transport: srv.Transport()}, "pub", "sec")
+	if err := out.SendToChat(context.Background(), "client1", "привет"); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 || reqs[0].Path != "/pushedMessages" || reqs[0].Auth != wantAuth {
+		t.Errorf("requests = %+v, want one authorized POST /pushedMessages", reqs)
+	}
+}
@@ -0,0 +1,49 @@
+package chatra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/outbox"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+)
+
+// NewOutboxSender — доставка записи outbox в Chatra.
+// Всё исходящее (ответы клиенту и заметки) идёт только через outbox:
+// пайплайн пишет запись в БД, диспетчер отправляет.
+func NewOutboxSender(outbounds Outbounds, tenants Tenants) outbox.SendFunc {
+	return func(ctx context.Context, item *outbox.Item) error {
+		t, err := tenants.Get(ctx, item.TenantID)
+		if errors.Is(err, tenant.ErrNotFound) {
+			return fmt.Errorf("%w: %v", outbox.ErrPermanent, err)
+		}
+		if err != nil {
+			return err
+		}
+		c := outbounds.For(t)
+
+		switch item.Kind {
+		case outbox.KindMessage:
+			err = c.SendToChat(ctx, item.ClientID, item.Text)
+		case outbox.KindNote:
+			err = c.SendNote(ctx, item.ClientID, item.Text)
+		default:
+			return fmt.Errorf("%w: unknown kind %q", outbox.ErrPermanent, item.Kind)
+		}
+
+		if err == nil {
+			return nil
+		}
+		if !Retryable(err) {
+			return fmt.Errorf("%w: %v", outbox.ErrPermanent, err)
+		}
+		// сообщение клиенту досылаем, только если Chatra его точно не приняла:
+		// после таймаута, 500, 502, 504 оно могло уйти, и повтор его задвоит
+		if item.Kind == outbox.KindMessage && !errors.Is(err, ErrCircuitOpen) && !retrySafe(http.MethodPost, err) {
+			return fmt.Errorf("%w: delivery unknown: %v", outbox.ErrPermanent, err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,66 @@
+package chatra_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/chatra"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/chatra/chatratest"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/outbox"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+)
+
+// oneTenant — тенант с ключами заглушки
+type oneTenant struct{}
+
+func (oneTenant) Get(_ context.Context, id string) (*tenant.Tenant, error) {
+	return &tenant.Tenant{ID: id, ChatraPublicKey: "pub", ChatraSecretKey: "sec"}, nil
+}
+
+func (oneTenant) BySecret(context.Context, string) (*tenant.Tenant, error) {
+	return nil, tenant.ErrNotFound
+}
+
+func TestOutboxSenderRedelivery(t *testing.T) {
+	tests := []struct {
+		name          string
+		kind          outbox.Kind
+		method, path  string
+		status        int
+		wantPermanent bool
+	}{
+		{"message 502 may be delivered", outbox.KindMessage, http.MethodPost, "/pushedMessages", http.StatusBadGateway, true},
+		{"message 500 may be delivered", outbox.KindMessage, http.MethodPost, "/pushedMessages", http.StatusInternalServerError, true},
+		{"message 503 was not accepted", outbox.KindMessage, http.MethodPost, "/pushedMessages", http.StatusServiceUnavailable, false},
+		{"message 429 was not accepted", outbox.KindMessage, http.MethodPost, "/pushedMessages", http.StatusTooManyRequests, false},
+		{"message 400 is permanent", outbox.KindMessage, http.MethodPost, "/pushedMessages", http.StatusBadRequest, true},
+		{"note 502 is retried", outbox.KindNote, http.MethodGet, "/clients/client1", http.StatusBadGateway, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := chatratest.NewServer()
+			defer srv.Close()
+			resp := chatratest.Response{Status: tt.status}
+			srv.On(tt.method, tt.path, resp, resp, resp)
+
+			cfg := srv.Config()
+			cfg.Retry = chatra.RetryPolicy{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+			send := chatra.NewOutboxSender(chatra.NewChatraOutbounds(cfg), oneTenant{})
+
+			err := send(context.Background(), &outbox.Item{TenantID: "t", ClientID: "client1", Kind: tt.kind, Text: "привет"})
+			if err == nil {
+				t.Fatal("want error")
+			}
+			if got := errors.Is(err, outbox.ErrPermanent); got != tt.wantPermanent {
+				t.Errorf("permanent = %v, want %v (err %v)", got, tt.wantPermanent, err)
+			}
+			if n := len(srv.Requests()); n != 1 {
+				t.Errorf("requests = %d, want 1", n)
+			}
+		})
+	}
+}
@@ -1,6 +1,21 @@
 package chatra
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+)
+
+var (
+	// ErrDuplicate — сообщение уже сохранено (повторная доставка)
+	ErrDuplicate = errors.New("duplicate message")
+	// ErrDraftNotFound — токена нет или он чужой
+	ErrDraftNotFound = errors.New("draft not found")
+	// ErrDraftClosed — черновик уже решён или токен истёк
+	ErrDraftClosed = errors.New("draft already decided or expired")
+)
 
 type Sender string
 
@@ -12,6 +27,7 @@ const (
 
 type Message struct {
 	ID          int64
+	TenantID    string
 	ChatID      string
 	Sender      Sender
 	Text        string
@@ -21,20 +37,137 @@ type Message struct {
 
 	ClientInfo        map[string]any
 	ClientIntegration map[string]any
+
+	// DedupKey — ключ идемпотентности входящего сообщения; пусто — без проверки
+	DedupKey string
+
+	// DeliveryStatus — для ответов AI: pending | sent | failed; пусто — не исходящее
+	DeliveryStatus string
+	// ReplyTo — для ответов AI: сообщение клиента, на которое отвечаем; 0 — неизвестно
+	ReplyTo int64
+
+	// RequestID и TraceParent — id запроса и трасса вебхука для логов и спанов; не хранятся
+	RequestID   string
+	TraceParent string
 }
+
+// Fragment — входящий chatFragment в том виде, в каком он лежит в очереди
+type Fragment struct {
+	// RequestID — id запроса вебхука: по нему в логах весь путь фрагмента
+	RequestID string `json:"request_id,omitempty"`
+	// TraceParent — W3C traceparent спана вебхука: обработка в воркере — та же трасса
+	TraceParent       string            `json:"trace_parent,omitempty"`
+	TenantID          string            `json:"tenant_id"`
+	ChatID            string            `json:"chat_id"`
+	ClientID          string            `json:"client_id"`
+	ClientInfo        map[string]any    `json:"client_info"`
+	ClientIntegration map[string]any    `json:"client_integration"`
+	Messages          []FragmentMessage `json:"messages"`
+	ReceivedAt        int64             `json:"received_at"` // unix, приём вебхука
+
+	// Retry — повторная попытка той же задачи: свои же сохранённые сообщения не дубли
+	Retry bool `json:"-"`
+}
+
+// FragmentMessage — поля как в вебхуке Chatra
+type FragmentMessage struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"` // client | agent | system
+	Text      string `json:"text"`
+	CreatedAt int64  `json:"createdAt"` // unix ms
+}
+
 type Outbound interface {
 	SendToChat(ctx context.Context, chatID string, text string) error
 	SendNote(ctx context.Context, chatID string, text string) error
 }
 
+// Outbounds — Chatra-клиент под креды конкретного тенанта
+type Outbounds interface {
+	For(t *tenant.Tenant) Outbound
+}
+
+// Tenants — поиск тенанта для входящего вебхука
+type Tenants interface {
+	Get(ctx context.Context, id string) (*tenant.Tenant, error)
+	BySecret(ctx context.Context, secret string) (*tenant.Tenant, error)
+}
+
+// CaseSource — база кейсов для FACT SELECTOR;
+// revisions: id кейса → id ревизии, на которой собран текст
+type CaseSource interface {
+	CasesPrompt(ctx context.Context, tenantID string) (prompt string, revisions map[string]int64, err error)
+}
+
 // Repo — persistence
 type Repo interface {
+	// SaveMessage — ErrDuplicate (msg.ID = id существующей записи), если DedupKey уже встречался
 	SaveMessage(ctx context.Context, msg *Message) error
-	GetHistory(ctx context.Context, chatID string) ([]Message, error)
+	GetHistory(ctx context.Context, tenantID string, chatID string) ([]Message, error)
+	SaveCaseRevisions(ctx context.Context, messageID int64, revisions map[string]int64) error
+
+	// SaveReply — ответ AI и запись outbox на его отправку одной транзакцией:
+	// в БД не бывает «ответили», если отправка не поставлена
+	SaveReply(ctx context.Context, msg *Message) error
+	// QueueNote — заметка оператору в outbox; replyTo — вопрос клиента, 0 — без привязки
+	QueueNote(ctx context.Context, tenantID, chatID, clientID string, replyTo int64, text string) error
+	// Answered — на это или более позднее сообщение клиента в чате уже есть ответ AI
+	// или заметка в outbox: пачку сообщений окна тишины закрывает один ответ на последнее
+	Answered(ctx context.Context, messageID int64) (bool, error)
+}
+
+type DraftStatus string
+
+const (
+	DraftPending  DraftStatus = "pending"
+	DraftApproved DraftStatus = "approved"
+	DraftRejected DraftStatus = "rejected"
+)
+
+// Draft — ответ AI, который оператор должен подтвердить, поправить или отклонить
+type Draft struct {
+	ID           int64
+	TenantID     string
+	ChatID       string
+	ClientID     string
+	MessageID    int64 // вопрос клиента; 0 — неизвестен
+	RunID        int64 // прогон пайплайна; 0 — без трассы
+	Text         string
+	FinalText    string
+	EditDistance int
+	Status       DraftStatus
+	// DecidedByClaimed — имя, которое оператор сам ввёл в форме; ссылка не знает,
+	// кто её открыл, поэтому имя ничем не подтверждено
+	DecidedByClaimed string
+	DecidedAt        *time.Time
+	SentMessageID    int64
+	ExpiresAt        time.Time
+	CreatedAt        time.Time
+}
+
+// Open — ещё можно решать
+func (d *Draft) Open(now time.Time) bool {
+	return d.Status == DraftPending && now.Before(d.ExpiresAt)
+}
+
+// Drafts — persistence черновиков
+type Drafts interface {
+	Create(ctx context.Context, d *Draft, tokenHash string) error
+	// ByToken — ErrDraftNotFound, если такого токена нет
+	ByToken(ctx context.Context, tokenHash string) (*Draft, error)
+	// Decide — перевести pending → status; ErrDraftClosed, если решён или истёк
+	Decide(ctx context.Context, id int64, status DraftStatus, claimedBy string) error
+	// Reopen — вернуть в pending, если отправка не удалась
+	Reopen(ctx context.Context, id int64) error
+	// Sent — что ушло клиенту
+	Sent(ctx context.Context, id int64, finalText string, editDistance int, sentMessageID int64) error
 }
 
 // Service — оркестрация (без return)
 type Service interface {
+	HandleFragment(ctx context.Context, f *Fragment) error
+	// HandleBurst — фрагменты чата за окно тишины: один ответ на все новые сообщения
+	HandleBurst(ctx context.Context, fs []*Fragment) error
 	HandleIncoming(ctx context.Context, msg *Message) error
 	SaveOnly(ctx context.Context, msg *Message) error
 }
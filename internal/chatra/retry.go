@@ -0,0 +1,127 @@
+package chatra
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrCircuitOpen — Chatra недавно сыпала ошибками, запрос не отправлялся
+var ErrCircuitOpen = errors.New("chatra circuit breaker open")
+
+// APIError — Chatra ответила не-2xx
+type APIError struct {
+	Status     int
+	StatusText string
+	Body       string
+	RetryAfter time.Duration // из заголовка Retry-After; 0 — не было
+}
+
+func (e *APIError) Error() string {
+	return "chatra api error: " + e.StatusText + " body=" + e.Body
+}
+
+// Retryable — имеет ли смысл повторить: сеть, 408, 429, 5xx, открытый breaker.
+// Остальные 4xx — ошибка в запросе или кредах, повтор не поможет.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status == http.StatusRequestTimeout ||
+			apiErr.Status == http.StatusTooManyRequests ||
+			apiErr.Status >= 500
+	}
+	// сеть, таймаут, обрыв соединения
+	return true
+}
+
+// serverFault — сбой на стороне Chatra или сети; считается в breaker
+func serverFault(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status >= 500
+	}
+	return true
+}
+
+// retrySafe — POST /pushedMessages не идемпотентен: повторяем, только если
+// Chatra его точно не приняла (429, 503, не смогли соединиться),
+// иначе клиент может получить ответ дважды
+func retrySafe(method string, err error) bool {
+	if method != http.MethodPost {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status == http.StatusTooManyRequests || apiErr.Status == http.StatusServiceUnavailable
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// RetryPolicy — повторы одной отправки внутри вызова
+type RetryPolicy struct {
+	MaxAttempts   int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	MaxRetryAfter time.Duration // дольше Retry-After не ждём — пусть досылает outbox
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 500 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 8 * time.Second
+	}
+	if p.MaxRetryAfter <= 0 {
+		p.MaxRetryAfter = 30 * time.Second
+	}
+	return p
+}
+
+// delay — Retry-After, если Chatra его прислала, иначе экспонента с полным джиттером
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, p.MaxRetryAfter)
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	return d/2 + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// parseRetryAfter — секунды или HTTP-дата
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
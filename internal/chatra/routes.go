@@ -1,7 +1,51 @@
 package chatra
 
-import "github.com/go-chi/chi/v5"
+import (
+	"net/http"
 
-func RegisterRoutes(r chi.Router, h *Handler) {
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/cases"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/dashboard"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/httpx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/pipeline"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+)
+
+// AdminHandlers — всё, что живёт под /admin за ADMIN_TOKEN
+type AdminHandlers struct {
+	Cases   *cases.Handler
+	Tenants *tenant.Handler
+	Queue   http.HandlerFunc
+	Runs    *pipeline.Handler
+	UI      *dashboard.Handler // серверная панель операторов
+}
+
+func RegisterRoutes(r chi.Router, h *Handler, approve *ApprovalHandler, admin AdminHandlers, adminToken string) {
 	r.Post("/chatra/webhook", h.HandleWebhook)
+	r.Post("/chatra/webhook/{tenant}", h.HandleWebhook)
+
+	// ссылки из заметок операторам: токен в пути и есть доступ
+	r.Get("/approve/{token}", approve.Page)
+	r.Get("/reject/{token}", approve.Page)
+	r.Post("/approve/{token}", approve.Approve)
+	r.Post("/reject/{token}", approve.Reject)
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(httpx.AdminOnly(adminToken))
+
+		// /admin/cases — кейсы тенанта default
+		r.Route("/cases", func(r chi.Router) {
+			cases.RegisterAdminRoutes(r, admin.Cases)
+		})
+		r.Route("/tenants", func(r chi.Router) {
+			tenant.RegisterAdminRoutes(r, admin.Tenants)
+			r.Route("/{tenant}/cases", func(r chi.Router) {
+				cases.RegisterAdminRoutes(r, admin.Cases)
+			})
+		})
+		r.Get("/queue", admin.Queue)
+		pipeline.RegisterAdminRoutes(r, admin.Runs)
+		dashboard.RegisterAdminRoutes(r, admin.UI)
+	})
 }
@@ -3,45 +3,220 @@ package chatra
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/Vovarama1992/chatra-ai-bridge/internal/ai"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/logx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/metrics"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/pipeline"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tracing"
 )
 
 type service struct {
-	repo     Repo
-	ai       ai.AI
-	outbound Outbound
+	repo      Repo
+	ai        ai.AI
+	cases     CaseSource
+	tenants   Tenants
+	runs      pipeline.Repo    // трассы прогонов
+	budget    *pipeline.Budget // лимит трат на модели; nil — без лимита
+	approvals *Approvals
 }
 
-var allowedModes = map[string]bool{}
-
-func NewService(repo Repo, aiClient ai.AI, outbound Outbound) Service {
+func NewService(
+	repo Repo,
+	aiClient ai.AI,
+	cases CaseSource,
+	tenants Tenants,
+	runs pipeline.Repo,
+	budget *pipeline.Budget,
+	approvals *Approvals,
+) Service {
 	return &service{
-		repo:     repo,
-		ai:       aiClient,
-		outbound: outbound,
+		repo:      repo,
+		ai:        aiClient,
+		cases:     cases,
+		tenants:   tenants,
+		runs:      runs,
+		budget:    budget,
+		approvals: approvals,
 	}
 }
 
 type aiFacts struct {
 	Facts []string `json:"facts"`
 	Mode  string   `json:"mode"`
+
+	// ревизии кейсов, из которых собран промпт
+	revisions map[string]int64
 }
 
+var caseRefRe = regexp.MustCompile(`CASE_\d{2}`)
+
 type aiAnswer struct {
 	Answer string   `json:"answer"`
 	Facts  []string `json:"facts"`
 	Mode   string   `json:"mode"`
 }
 
+// stageSchemas — какой JSON ждём от каждого этапа; тот же формат, что в промптах
+var stageSchemas = map[string]*ai.Schema{
+	ai.StageFactSelector: ai.Object(map[string]*ai.Schema{
+		"facts": ai.Array(ai.String()),
+		"mode":  ai.Enum("SELF_CONFIDENCE", "NEED_OPERATOR"),
+	}),
+	ai.StageFactValidator: ai.Object(map[string]*ai.Schema{
+		"facts": ai.Array(ai.String()),
+		"mode":  ai.Enum("SELF_CONFIDENCE", "NEED_OPERATOR"),
+	}),
+	ai.StageAnswerBuilder: ai.Object(map[string]*ai.Schema{
+		"answer": ai.String(),
+		"facts":  ai.Array(ai.String()),
+		"mode":   ai.Enum("SELF_CONFIDENCE", "NEED_OPERATOR"),
+	}),
+	ai.StageAnswerValidator: ai.Object(map[string]*ai.Schema{
+		"mode": ai.Enum("SELF_CONFIDENCE", "NEED_OPERATOR"),
+	}),
+}
+
+// HandleFragment — все сообщения фрагмента по порядку, ответ на каждое; ошибка — повтор задачи
+func (s *service) HandleFragment(ctx context.Context, f *Fragment) error {
+	return s.saveFragment(ctx, f, func(msg *Message) error {
+		return s.answer(ctx, msg)
+	})
+}
+
+// saveFragment — сохранить сообщения фрагмента; onClient — для каждого
+// сообщения клиента, на которое ещё нет ответа
+func (s *service) saveFragment(ctx context.Context, f *Fragment, onClient func(msg *Message) error) error {
+	for i, m := range f.Messages {
+		// текст — только на debug: в нём персональные данные клиента
+		slog.DebugContext(ctx, "fragment message", "index", i, "type", m.Type, "text", short(m.Text))
+
+		if m.Text == "" {
+			continue
+		}
+
+		switch m.Type {
+
+		case "client":
+			msg := &Message{
+				TenantID:          f.TenantID,
+				ChatID:            f.ChatID,
+				Sender:            SenderClient,
+				Text:              m.Text,
+				ClientID:          &f.ClientID,
+				ClientInfo:        f.ClientInfo,
+				ClientIntegration: f.ClientIntegration,
+				DedupKey:          messageDedupKey(f.ChatID, m),
+				RequestID:         f.RequestID,
+				TraceParent:       tracing.Inject(ctx),
+			}
+
+			if err := s.repo.SaveMessage(ctx, msg); err != nil {
+				if !errors.Is(err, ErrDuplicate) {
+					return err
+				}
+				// повтор задачи: сообщение сохранила прошлая попытка, ответа могло не быть
+				if !f.Retry {
+					s.logDuplicate(ctx, msg)
+					continue
+				}
+				// а мог и быть: фрагмент упал на следующем сообщении
+				answered, err := s.repo.Answered(ctx, msg.ID)
+				if err != nil {
+					return err
+				}
+				if answered {
+					slog.InfoContext(ctx, "retry: message already answered", "message_id", msg.ID)
+					continue
+				}
+			}
+
+			if err := onClient(msg); err != nil {
+				return err
+			}
+
+		case "agent":
+			msg := &Message{
+				TenantID: f.TenantID,
+				ChatID:   f.ChatID,
+				Sender:   SenderSupporter,
+				Text:     m.Text,
+				ClientID: &f.ClientID,
+				DedupKey: messageDedupKey(f.ChatID, m),
+			}
+			if err := s.SaveOnly(ctx, msg); err != nil {
+				if !errors.Is(err, ErrDuplicate) {
+					return err
+				}
+				if !f.Retry {
+					s.logDuplicate(ctx, msg)
+				}
+			}
+
+		case "system":
+			// игнорируем системные сообщения
+			continue
+		}
+	}
+
+	return nil
+}
+
 func (s *service) HandleIncoming(ctx context.Context, msg *Message) error {
-	log.Println("========== NEW MESSAGE ==========")
-	log.Printf("[svc] chatId=%s text=%q", msg.ChatID, msg.Text)
+	if err := s.repo.SaveMessage(ctx, msg); err != nil {
+		if errors.Is(err, ErrDuplicate) {
+			s.logDuplicate(ctx, msg)
+			return nil
+		}
+		return err
+	}
+	return s.answer(ctx, msg)
+}
 
-	_ = s.repo.SaveMessage(ctx, msg)
-	history, _ := s.repo.GetHistory(ctx, msg.ChatID)
+func (s *service) logDuplicate(ctx context.Context, msg *Message) {
+	metrics.Dedup.WithLabelValues(dedupKind(msg.DedupKey)).Inc()
+	slog.InfoContext(ctx, "duplicate message skipped",
+		"sender", msg.Sender, "message_id", msg.ID, "dedup_key", msg.DedupKey,
+	)
+}
+
+// answer — AI-пайплайн по уже сохранённому сообщению клиента
+func (s *service) answer(ctx context.Context, msg *Message) error {
+	ctx, span := tracing.Start(ctx, "pipeline.answer",
+		attribute.String("tenant.id", msg.TenantID),
+		attribute.String("chat.id", msg.ChatID),
+	)
+	defer span.End()
+
+	slog.InfoContext(ctx, "pipeline start", "message_id", msg.ID)
+	slog.DebugContext(ctx, "pipeline input", "text", short(msg.Text))
+
+	t, err := s.tenants.Get(ctx, msg.TenantID)
+	if err != nil {
+		return err
+	}
+	rec, err := pipeline.Start(ctx, s.runs, msg.TenantID, msg.ChatID, msg.ID)
+	if err != nil {
+		// без прогона траты не посчитаются — задача повторится позже
+		return err
+	}
+	if id := rec.RunID(); id != 0 {
+		ctx = logx.With(ctx, "run_id", id)
+		span.SetAttributes(attribute.Int64("pipeline.run_id", id))
+	}
+
+	history, _ := s.repo.GetHistory(ctx, msg.TenantID, msg.ChatID)
 
 	aiHistory := make([]ai.Message, 0, len(history))
 	for _, m := range history {
@@ -56,8 +231,11 @@ func (s *service) HandleIncoming(ctx context.Context, msg *Message) error {
 	integrationData, _ := json.Marshal(msg.ClientIntegration)
 
 	// STEP 1 — FACT SELECTOR
-	factsResp, _ := s.selectFacts(
-		ctx,
+	stageCtx, stage := startStage(ctx, "selectFacts", msg.ChatID)
+	factsResp, stageErr := s.selectFacts(
+		stageCtx,
+		rec,
+		t,
 		aiHistory,
 		msg.Text,
 		string(clientInfo),
@@ -67,20 +245,44 @@ func (s *service) HandleIncoming(ctx context.Context, msg *Message) error {
 	if factsResp.Mode == "" {
 		factsResp.Mode = "PARSE_ERROR"
 	}
+	endStage(stage, factsResp.Mode, stageErr)
+	if ctx.Err() != nil {
+		return interrupted(ctx, rec)
+	}
+	if errors.Is(stageErr, errNoCases) {
+		slog.ErrorContext(ctx, "cases load failed", "err", stageErr)
+		finish(ctx, rec, factsResp.Mode, pipeline.OutcomeSkipped, stageErr)
+		return stageErr
+	}
+
+	if used := usedRevisions(factsResp); len(used) > 0 && msg.ID != 0 {
+		if err := s.repo.SaveCaseRevisions(ctx, msg.ID, used); err != nil {
+			slog.ErrorContext(ctx, "save case revisions failed", "err", err)
+		}
+	}
 
 	currentMode := factsResp.Mode
 	answerResp := aiAnswer{}
 
 	// STEP 2 — FACT VALIDATOR
-	if mode, _ := s.validateFacts(ctx, aiHistory, msg.Text, factsResp.Facts); mode != "" {
+	stageCtx, stage = startStage(ctx, "validateFacts", msg.ChatID)
+	mode, stageErr := s.validateFacts(stageCtx, rec, t, aiHistory, msg.Text, factsResp.Facts)
+	endStage(stage, mode, stageErr)
+	if ctx.Err() != nil {
+		return interrupted(ctx, rec)
+	}
+	if mode != "" {
 		currentMode = mode
 	}
 
 	// STEP 3–4 — ТОЛЬКО ЕСЛИ SELF_CONFIDENCE
 	if currentMode == "SELF_CONFIDENCE" {
 
-		answerResp, _ = s.buildAnswer(
-			ctx,
+		stageCtx, stage = startStage(ctx, "buildAnswer", msg.ChatID)
+		answerResp, stageErr = s.buildAnswer(
+			stageCtx,
+			rec,
+			t,
 			aiHistory,
 			msg.Text,
 			factsResp.Facts,
@@ -89,10 +291,20 @@ func (s *service) HandleIncoming(ctx context.Context, msg *Message) error {
 		if answerResp.Mode == "" {
 			answerResp.Mode = "PARSE_ERROR"
 		}
+		endStage(stage, answerResp.Mode, stageErr)
+		if ctx.Err() != nil {
+			return interrupted(ctx, rec)
+		}
 
 		currentMode = answerResp.Mode
 
-		if mode, _ := s.validateAnswer(ctx, msg.Text, answerResp.Answer, answerResp.Facts); mode != "" {
+		stageCtx, stage = startStage(ctx, "validateAnswer", msg.ChatID)
+		mode, stageErr = s.validateAnswer(stageCtx, rec, t, msg.Text, answerResp.Answer, answerResp.Facts)
+		endStage(stage, mode, stageErr)
+		if ctx.Err() != nil {
+			return interrupted(ctx, rec)
+		}
+		if mode != "" {
 			currentMode = mode
 		}
 	}
@@ -115,73 +327,205 @@ Answer:
 ` + answerResp.Answer + `
 `
 
-	if allowedModes[currentMode] {
+	delivery, source := t.Delivery.Resolve(segmentFields(msg), usedCases(factsResp.Facts))
+	// бюджет на модели исчерпан — клиентам ничего не уходит, только заметки
+	if delivery == tenant.DeliveryLive || delivery == tenant.DeliveryApprove {
+		if over, reason := s.budget.Exceeded(ctx); over {
+			slog.WarnContext(ctx, "ai budget exceeded, delivery downgraded", "reason", reason)
+			delivery, source = tenant.DeliveryNoteOnly, "budget"
+		}
+	}
+	rec.Delivery(string(delivery), source)
+	span.SetAttributes(attribute.String("pipeline.delivery", string(delivery)))
+	slog.InfoContext(ctx, "pipeline delivery", "mode", currentMode, "delivery", delivery, "source", source)
 
-		log.Println("========== SEND TO CHAT ==========")
-		log.Printf("Mode: %s", currentMode)
-		log.Printf("Answer: %s", answerResp.Answer)
+	// TEMP CHECK — не спамим операторов
+	if currentMode != "SELF_CONFIDENCE" {
+		slog.InfoContext(ctx, "pipeline skipped, no note", "mode", currentMode)
+		finish(ctx, rec, currentMode, pipeline.OutcomeSkipped, nil)
+		return nil
+	}
+
+	// без текста отправлять клиенту нечего — пусть посмотрит оператор
+	if answerResp.Answer == "" && (delivery == tenant.DeliveryLive || delivery == tenant.DeliveryApprove) {
+		delivery = tenant.DeliveryNoteOnly
+	}
+
+	switch delivery {
 
-		_ = s.repo.SaveMessage(ctx, &Message{
-			ChatID: msg.ChatID,
-			Sender: SenderAI,
-			Text:   answerResp.Answer,
+	case tenant.DeliveryShadow:
+		slog.InfoContext(ctx, "shadow: answer stays in trace")
+		finish(ctx, rec, currentMode, pipeline.OutcomeShadow, nil)
+		return nil
+
+	case tenant.DeliveryLive:
+		slog.InfoContext(ctx, "sending answer to chat", "answer_len", len(answerResp.Answer))
+		slog.DebugContext(ctx, "answer", "text", short(answerResp.Answer))
+
+		// ответ и его отправка — одной транзакцией, доставит outbox
+		err := s.repo.SaveReply(ctx, &Message{
+			TenantID: msg.TenantID,
+			ChatID:   msg.ChatID,
+			Sender:   SenderAI,
+			Text:     answerResp.Answer,
+			ClientID: msg.ClientID,
+			ReplyTo:  msg.ID,
 		})
+		finish(ctx, rec, currentMode, pipeline.OutcomeSent, err)
+		return err
+
+	case tenant.DeliveryApprove:
+		link, err := s.approvals.Issue(ctx, msg, rec.RunID(), answerResp.Answer)
+		if err != nil {
+			// без ссылки оператор всё равно увидит черновик и ответит сам
+			slog.ErrorContext(ctx, "issue draft failed", "err", err)
+			link = "(ссылка недоступна, ответьте вручную)"
+		}
+		note = "[ЖДЁТ ПОДТВЕРЖДЕНИЯ ОПЕРАТОРА]\nОтправить, поправить или отклонить: " + link + "\n" + note
+
+		slog.InfoContext(ctx, "queueing approval note", "note_len", len(note))
 
-		return s.outbound.SendToChat(ctx, *msg.ClientID, answerResp.Answer)
+		err = s.repo.QueueNote(ctx, msg.TenantID, msg.ChatID, *msg.ClientID, msg.ID, note)
+		finish(ctx, rec, currentMode, pipeline.OutcomeApproval, err)
+		return err
 	}
 
-	// TEMP CHECK — не спамим операторов
-	if currentMode != "SELF_CONFIDENCE" {
-		log.Printf("[TEMP] skip note, mode=%s", currentMode)
-		return nil
+	slog.InfoContext(ctx, "queueing operator note", "note_len", len(note))
+
+	err = s.repo.QueueNote(ctx, msg.TenantID, msg.ChatID, *msg.ClientID, msg.ID, note)
+	finish(ctx, rec, currentMode, pipeline.OutcomeNote, err)
+	return err
+}
+
+// finish — итог прогона в трассу, метрики и спан
+func finish(ctx context.Context, rec *pipeline.Recorder, mode, outcome string, err error) {
+	if err != nil && ctx.Err() != nil {
+		interrupted(ctx, rec)
+		return
+	}
+	label := outcome
+	if err != nil {
+		label = "error"
+		trace.SpanFromContext(ctx).RecordError(err)
+		trace.SpanFromContext(ctx).SetStatus(codes.Error, err.Error())
 	}
+	metrics.FinalMode.WithLabelValues(mode, label).Inc()
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("pipeline.mode", mode),
+		attribute.String("pipeline.outcome", outcome),
+	)
+	rec.Finish(ctx, mode, outcome, err)
+}
+
+// interrupted — остановка процесса посреди прогона: это не AI_ERROR и не итог
+// для метрик; задача вернётся в очередь, прогон повторится
+func interrupted(ctx context.Context, rec *pipeline.Recorder) error {
+	slog.WarnContext(ctx, "pipeline interrupted", "err", ctx.Err())
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("pipeline.outcome", pipeline.OutcomeCancelled))
+	rec.Finish(ctx, "", pipeline.OutcomeCancelled, nil)
+	return ctx.Err()
+}
+
+// startStage — спан этапа; модель допишет ask, режим — endStage
+func startStage(ctx context.Context, name, chatID string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "pipeline."+name, attribute.String("chat.id", chatID))
+}
 
-	log.Println("========== NOTE TO OPERATOR ==========")
-	log.Println(note)
+func endStage(span trace.Span, mode string, err error) {
+	tracing.End(span, err, attribute.String("pipeline.mode", mode))
+}
 
-	return s.outbound.SendNote(ctx, *msg.ClientID, note)
+// segmentFields — поля клиента, по которым настраиваются сегменты доставки
+func segmentFields(msg *Message) map[string]any {
+	fields := make(map[string]any, len(msg.ClientInfo)+len(msg.ClientIntegration)+1)
+	for k, v := range msg.ClientIntegration {
+		fields[k] = v
+	}
+	for k, v := range msg.ClientInfo {
+		fields[k] = v
+	}
+	fields["chat_id"] = msg.ChatID
+	return fields
+}
+
+// usedCases — кейсы, на которые опираются выбранные факты
+func usedCases(facts []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, f := range facts {
+		for _, id := range caseRefRe.FindAllString(f, -1) {
+			if !seen[id] {
+				seen[id] = true
+				out = append(out, id)
+			}
+		}
+	}
+	return out
 }
 
 // ------------------------------------------------------------
 
 func (s *service) selectFacts(
 	ctx context.Context,
+	rec *pipeline.Recorder,
+	t *tenant.Tenant,
 	history []ai.Message,
 	lastUserText string,
 	clientInfo string,
 	integrationData string,
 ) (aiFacts, error) {
 
+	cases, revisions, err := s.cases.CasesPrompt(ctx, t.ID)
+	if err == nil && strings.TrimSpace(cases) == "" {
+		err = errors.New("cases prompt is empty")
+	}
+	if err != nil {
+		// без кейсов модель ответит из головы — этап не запускаем
+		return aiFacts{Mode: "AI_ERROR"}, fmt.Errorf("%w: %v", errNoCases, err)
+	}
+
 	input := map[string]any{
 		"history":                 history,
 		"last_user_text":          lastUserText,
 		"client_info":             clientInfo,
 		"client_integration_data": integrationData,
-		"cases":                   NotVPNDomainPrompt,
-	}
-
-	b, _ := json.Marshal(input)
-
-	raw, err := s.ai.GetReply(ctx, FactSelectorPrompt, string(b))
-	if err != nil {
-		return aiFacts{Mode: "AI_ERROR"}, err
+		"cases":                   cases,
 	}
 
 	var resp aiFacts
-	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
-		log.Println("[FACT_SELECTOR JSON ERROR]", err)
-		return aiFacts{Mode: "PARSE_ERROR"}, nil
+	if _, err := s.ask(ctx, rec, t, ai.StageFactSelector, FactSelectorPrompt, input, &resp); err != nil {
+		if errors.Is(err, errParse) {
+			slog.WarnContext(ctx, "stage response not parsed", "stage", ai.StageFactSelector, "err", err)
+			return aiFacts{Mode: "PARSE_ERROR"}, nil
+		}
+		return aiFacts{Mode: "AI_ERROR"}, err
 	}
 
 	if resp.Mode == "" {
 		resp.Mode = "PARSE_ERROR"
 	}
 
+	resp.revisions = revisions
 	return resp, nil
 }
 
+// usedRevisions — ревизии кейсов, упомянутых в выбранных фактах
+func usedRevisions(facts aiFacts) map[string]int64 {
+	out := map[string]int64{}
+	for _, f := range facts.Facts {
+		for _, id := range caseRefRe.FindAllString(f, -1) {
+			if rev, ok := facts.revisions[id]; ok {
+				out[id] = rev
+			}
+		}
+	}
+	return out
+}
+
 func (s *service) validateFacts(
 	ctx context.Context,
+	rec *pipeline.Recorder,
+	t *tenant.Tenant,
 	history []ai.Message,
 	lastUserText string,
 	facts []string,
@@ -193,20 +537,18 @@ func (s *service) validateFacts(
 		"facts":          facts,
 	}
 
-	b, _ := json.Marshal(input)
-
-	raw, err := s.ai.GetReply(ctx, FactValidatorPrompt, string(b))
-	if err != nil {
+	var resp struct {
+		Mode string `json:"mode"`
+	}
+	raw, err := s.ask(ctx, rec, t, ai.StageFactValidator, FactValidatorPrompt, input, &resp)
+	if err != nil && !errors.Is(err, errParse) {
 		return "AI_ERROR", err
 	}
 
-	log.Printf("[FACT_VALIDATOR][RAW] %s", short(raw))
+	slog.DebugContext(ctx, "stage raw output", "stage", ai.StageFactValidator, "raw", short(raw))
 
-	var resp struct {
-		Mode string `json:"mode"`
-	}
-	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
-		log.Printf("[FACT_VALIDATOR][JSON_ERR] %v", err)
+	if err != nil {
+		slog.WarnContext(ctx, "stage response not parsed", "stage", ai.StageFactValidator, "err", err)
 		return "PARSE_ERROR", nil
 	}
 
@@ -219,6 +561,8 @@ func (s *service) validateFacts(
 
 func (s *service) buildAnswer(
 	ctx context.Context,
+	rec *pipeline.Recorder,
+	t *tenant.Tenant,
 	history []ai.Message,
 	lastUserText string,
 	facts []string,
@@ -230,17 +574,13 @@ func (s *service) buildAnswer(
 		"facts":          facts,
 	}
 
-	b, _ := json.Marshal(input)
-
-	raw, err := s.ai.GetReply(ctx, AnswerBuilderPrompt, string(b))
-	if err != nil {
-		return aiAnswer{Mode: "AI_ERROR"}, err
-	}
-
 	var resp aiAnswer
-	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
-		log.Println("[ANSWER_BUILDER JSON ERROR]", err)
-		return aiAnswer{Mode: "PARSE_ERROR"}, nil
+	if _, err := s.ask(ctx, rec, t, ai.StageAnswerBuilder, AnswerBuilderPrompt, input, &resp); err != nil {
+		if errors.Is(err, errParse) {
+			slog.WarnContext(ctx, "stage response not parsed", "stage", ai.StageAnswerBuilder, "err", err)
+			return aiAnswer{Mode: "PARSE_ERROR"}, nil
+		}
+		return aiAnswer{Mode: "AI_ERROR"}, err
 	}
 
 	if resp.Mode == "" {
@@ -252,6 +592,8 @@ func (s *service) buildAnswer(
 
 func (s *service) validateAnswer(
 	ctx context.Context,
+	rec *pipeline.Recorder,
+	t *tenant.Tenant,
 	lastUserText string,
 	answer string,
 	facts []string,
@@ -263,20 +605,18 @@ func (s *service) validateAnswer(
 		"facts":          facts,
 	}
 
-	b, _ := json.Marshal(input)
-
-	raw, err := s.ai.GetReply(ctx, AnswerValidatorPrompt, string(b))
-	if err != nil {
+	var resp struct {
+		Mode string `json:"mode"`
+	}
+	raw, err := s.ask(ctx, rec, t, ai.StageAnswerValidator, AnswerValidatorPrompt, input, &resp)
+	if err != nil && !errors.Is(err, errParse) {
 		return "AI_ERROR", err
 	}
 
-	log.Printf("[ANSWER_VALIDATOR][RAW] %s", short(raw))
+	slog.DebugContext(ctx, "stage raw output", "stage", ai.StageAnswerValidator, "raw", short(raw))
 
-	var resp struct {
-		Mode string `json:"mode"`
-	}
-	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
-		log.Printf("[ANSWER_VALIDATOR][JSON_ERR] %v", err)
+	if err != nil {
+		slog.WarnContext(ctx, "stage response not parsed", "stage", ai.StageAnswerValidator, "err", err)
 		return "PARSE_ERROR", nil
 	}
 
@@ -287,10 +627,88 @@ func (s *service) validateAnswer(
 	return resp.Mode, nil
 }
 
+// errParse — модель ответила, но не тем JSON, что ждали
+var errParse = errors.New("parse ai response")
+
+// errNoCases — база кейсов тенанта недоступна или пуста: прогон — AI_ERROR,
+// задача повторится
+var errNoCases = errors.New("cases unavailable")
+
+// ask — вызов этапа с записью шага в трассу; ответ разбирается в out
+func (s *service) ask(
+	ctx context.Context,
+	rec *pipeline.Recorder,
+	t *tenant.Tenant,
+	stage string,
+	defaultPrompt string,
+	input any,
+	out any,
+) (string, error) {
+
+	b, _ := json.Marshal(input)
+	prompt := t.Prompt(stage, defaultPrompt)
+
+	started := time.Now()
+	result := "ok"
+	defer func() {
+		metrics.StageDuration.WithLabelValues(stage, result).Observe(time.Since(started).Seconds())
+	}()
+
+	opts := t.AIOptions(stage)
+	opts.Schema = stageSchemas[stage]
+	reply, err := s.ai.GetReply(ctx, opts, prompt, string(b))
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("ai.provider", reply.Provider),
+		attribute.String("ai.model", reply.Model),
+		attribute.Bool("ai.cached", reply.Cached),
+	)
+
+	step := pipeline.Step{
+		Stage:            stage,
+		PromptVersion:    pipeline.PromptVersion(prompt),
+		Provider:         reply.Provider,
+		Model:            reply.Model,
+		Input:            b,
+		RawOutput:        reply.Text,
+		LatencyMs:        time.Since(started).Milliseconds(),
+		PromptTokens:     reply.Usage.PromptTokens,
+		CompletionTokens: reply.Usage.CompletionTokens,
+		TotalTokens:      reply.Usage.TotalTokens,
+		CostUSD:          reply.CostUSD,
+		Cached:           reply.Cached,
+	}
+
+	if errors.Is(err, ai.ErrInvalidJSON) {
+		result = "parse_error"
+		err = fmt.Errorf("%w: %v", errParse, err)
+		step.Error = err.Error()
+		rec.Step(ctx, step, nil)
+		return reply.Text, err
+	}
+	if err != nil {
+		result = "error"
+		step.Error = err.Error()
+		rec.Step(ctx, step, nil)
+		return "", err
+	}
+
+	if err := json.Unmarshal([]byte(reply.Text), out); err != nil {
+		result = "parse_error"
+		err = fmt.Errorf("%w: %v", errParse, err)
+		step.Error = err.Error()
+		rec.Step(ctx, step, nil)
+		return reply.Text, err
+	}
+
+	rec.Step(ctx, step, out)
+	return reply.Text, nil
+}
+
 // ------------------------------------------------------------
 
 func (s *service) sendFullNote(
 	ctx context.Context,
+	t *tenant.Tenant,
 	msg *Message,
 	stage string,
 	facts aiFacts,
@@ -314,16 +732,13 @@ Answer:
 ` + answer + `
 `
 
-	log.Println("========== NOTE TO OPERATOR ==========")
-	log.Println(note)
+	slog.InfoContext(ctx, "queueing operator note", "stage", stage, "note_len", len(note))
 
-	return s.outbound.SendNote(ctx, *msg.ClientID, note)
+	return s.repo.QueueNote(ctx, t.ID, msg.ChatID, *msg.ClientID, msg.ID, note)
 }
 
 func (s *service) SaveOnly(ctx context.Context, msg *Message) error {
-	log.Printf("[svc] save only chatId=%s sender=%s text=%q",
-		msg.ChatID, msg.Sender, msg.Text,
-	)
+	slog.DebugContext(ctx, "message saved without answer", "sender", msg.Sender, "text", short(msg.Text))
 	return s.repo.SaveMessage(ctx, msg)
 }
 
@@ -0,0 +1,238 @@
+package chatra
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/ai"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/pipeline"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tenant"
+)
+
+// fakeRepo — сообщения и исходящие в памяти
+type fakeRepo struct {
+	mu       sync.Mutex
+	messages []Message
+	replies  []Message
+	notes    []int64 // replyTo заметок
+
+	// failReply — сколько раз отказать SaveReply на это сообщение клиента
+	failReply map[int64]int
+}
+
+func (r *fakeRepo) SaveMessage(_ context.Context, msg *Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.messages {
+		if msg.DedupKey != "" && m.DedupKey == msg.DedupKey {
+			msg.ID = m.ID
+			return ErrDuplicate
+		}
+	}
+	msg.ID = int64(len(r.messages) + 1)
+	r.messages = append(r.messages, *msg)
+	return nil
+}
+
+func (r *fakeRepo) GetHistory(context.Context, string, string) ([]Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.messages...), nil
+}
+
+func (r *fakeRepo) SaveCaseRevisions(context.Context, int64, map[string]int64) error { return nil }
+
+func (r *fakeRepo) SaveReply(_ context.Context, msg *Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failReply[msg.ReplyTo] > 0 {
+		r.failReply[msg.ReplyTo]--
+		return errors.New("db is down")
+	}
+	r.replies = append(r.replies, *msg)
+	return nil
+}
+
+func (r *fakeRepo) QueueNote(_ context.Context, _, _, _ string, replyTo int64, _ string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notes = append(r.notes, replyTo)
+	return nil
+}
+
+// Answered — как в БД: ответ на это или более позднее сообщение (в тестах чат один)
+func (r *fakeRepo) Answered(_ context.Context, id int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.replies {
+		if m.ReplyTo >= id {
+			return true, nil
+		}
+	}
+	for _, n := range r.notes {
+		if n >= id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// confidentAI — уверенный ответ на каждом этапе
+type confidentAI struct{}
+
+func (confidentAI) GetReply(_ context.Context, opts ai.Options, _, _ string) (ai.Reply, error) {
+	text := map[string]string{
+		ai.StageFactSelector:    `{"facts":["CASE_01 факт"],"mode":"SELF_CONFIDENCE"}`,
+		ai.StageFactValidator:   `{"facts":["CASE_01 факт"],"mode":"SELF_CONFIDENCE"}`,
+		ai.StageAnswerBuilder:   `{"answer":"ответ","facts":["CASE_01 факт"],"mode":"SELF_CONFIDENCE"}`,
+		ai.StageAnswerValidator: `{"mode":"SELF_CONFIDENCE"}`,
+	}[opts.Stage]
+	return ai.Reply{Text: text, Model: "test"}, nil
+}
+
+type staticCases string
+
+func (c staticCases) CasesPrompt(context.Context, string) (string, map[string]int64, error) {
+	return string(c), nil, nil
+}
+
+type liveTenants struct{}
+
+func (liveTenants) Get(_ context.Context, id string) (*tenant.Tenant, error) {
+	return &tenant.Tenant{ID: id, Delivery: tenant.DeliveryPolicy{Default: tenant.DeliveryLive}}, nil
+}
+
+func (liveTenants) BySecret(context.Context, string) (*tenant.Tenant, error) {
+	return nil, tenant.ErrNotFound
+}
+
+func newTestService(repo Repo) *service {
+	return NewService(repo, confidentAI{}, staticCases("CASE_01"), liveTenants{}, nil, nil, nil).(*service)
+}
+
+func TestHandleFragmentRetryAnswersOnlyUnanswered(t *testing.T) {
+	repo := &fakeRepo{failReply: map[int64]int{2: 1}}
+	svc := newTestService(repo)
+
+	f := &Fragment{
+		TenantID: "t",
+		ChatID:   "chat",
+		ClientID: "client",
+		Messages: []FragmentMessage{
+			{ID: "m1", Type: "client", Text: "первый вопрос"},
+			{ID: "m2", Type: "client", Text: "второй вопрос"},
+		},
+	}
+
+	if err := svc.HandleFragment(context.Background(), f); err == nil {
+		t.Fatal("first attempt: want error on second message")
+	}
+
+	retry := *f
+	retry.Retry = true
+	if err := svc.HandleFragment(context.Background(), &retry); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+
+	got := map[int64]int{}
+	for _, m := range repo.replies {
+		got[m.ReplyTo]++
+	}
+	if len(repo.replies) != 2 || got[1] != 1 || got[2] != 1 {
+		t.Errorf("replies by question = %v, want one per message", got)
+	}
+}
+
+func TestHandleFragmentRedeliverySkipsSaved(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := newTestService(repo)
+
+	f := &Fragment{
+		TenantID: "t",
+		ChatID:   "chat",
+		ClientID: "client",
+		Messages: []FragmentMessage{{ID: "m1", Type: "client", Text: "вопрос"}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := svc.HandleFragment(context.Background(), f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(repo.replies) != 1 {
+		t.Errorf("replies = %d, want 1", len(repo.replies))
+	}
+}
+
+// brokenRuns — трассы недоступны
+type brokenRuns struct{ pipeline.Repo }
+
+func (brokenRuns) StartRun(context.Context, *pipeline.Run) error { return errors.New("db is down") }
+
+// countingAI — считает платные вызовы
+type countingAI struct{ calls int }
+
+func (a *countingAI) GetReply(ctx context.Context, opts ai.Options, system, input string) (ai.Reply, error) {
+	a.calls++
+	return confidentAI{}.GetReply(ctx, opts, system, input)
+}
+
+func TestAnswerWithoutRunSkipsPaidStages(t *testing.T) {
+	model := &countingAI{}
+	repo := &fakeRepo{}
+	svc := NewService(repo, model, staticCases("CASE_01"), liveTenants{}, brokenRuns{}, nil, nil).(*service)
+
+	clientID := "client"
+	err := svc.answer(context.Background(), &Message{ID: 1, TenantID: "t", ChatID: "chat", Text: "вопрос", ClientID: &clientID})
+	if err == nil {
+		t.Fatal("want error so the job is retried")
+	}
+	if model.calls != 0 {
+		t.Errorf("model called %d times without a run to record the cost", model.calls)
+	}
+	if len(repo.replies)+len(repo.notes) != 0 {
+		t.Errorf("answer sent without a run")
+	}
+}
+
+// failingCases — база кейсов недоступна
+type failingCases struct{}
+
+func (failingCases) CasesPrompt(context.Context, string) (string, map[string]int64, error) {
+	return "", nil, errors.New("db is down")
+}
+
+func TestAnswerWithoutCasesIsAIError(t *testing.T) {
+	tests := []struct {
+		name  string
+		cases CaseSource
+	}{
+		{"cases load failed", failingCases{}},
+		{"cases prompt empty", staticCases("")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &countingAI{}
+			runs := &fakeRuns{}
+			repo := &fakeRepo{}
+			svc := NewService(repo, model, tt.cases, liveTenants{}, runs, nil, nil).(*service)
+
+			clientID := "client"
+			err := svc.answer(context.Background(), &Message{ID: 1, TenantID: tenant.DefaultID, ChatID: "chat", Text: "вопрос", ClientID: &clientID})
+			if !errors.Is(err, errNoCases) {
+				t.Fatalf("err = %v, want errNoCases so the job is retried", err)
+			}
+			if model.calls != 0 {
+				t.Errorf("model called %d times without cases", model.calls)
+			}
+			if len(runs.finished) != 1 || runs.finished[0].FinalMode != "AI_ERROR" || runs.finished[0].Error == "" {
+				t.Errorf("runs = %+v, want one AI_ERROR run with the error", runs.finished)
+			}
+			if len(repo.replies)+len(repo.notes) != 0 {
+				t.Errorf("something sent without cases")
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package dashboard
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/httpx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/pipeline"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+const (
+	conversationsLimit = 100
+	messagesLimit      = 200
+	runsLimit          = 50
+	commentMaxLen      = 4000
+)
+
+// Handler — серверная панель для старших операторов: диалоги, прогоны, разметка
+type Handler struct {
+	repo Repo
+	runs pipeline.Repo
+	tmpl *template.Template
+}
+
+func NewHandler(repo Repo, runs pipeline.Repo) *Handler {
+	tmpl := template.Must(template.New("").Funcs(template.FuncMap{
+		"path": url.PathEscape,
+		"time": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+		"cut": func(s string, n int) string {
+			r := []rune(s)
+			if len(r) <= n {
+				return s
+			}
+			return string(r[:n]) + "…"
+		},
+	}).ParseFS(templatesFS, "templates/*.html"))
+
+	return &Handler{repo: repo, runs: runs, tmpl: tmpl}
+}
+
+// Index — GET /admin/ui/: последние диалоги, ?tenant= — фильтр
+func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenant")
+
+	list, err := h.repo.Conversations(r.Context(), tenantID, conversationsLimit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "dashboard: conversations error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, "index.html", map[string]any{
+		"Tenant":        tenantID,
+		"Conversations": list,
+	})
+}
+
+// Chat — GET /admin/ui/chats/{tenant}/{chat}: лента сообщений и прогоны пайплайна
+func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
+	tenantID := chi.URLParam(r, "tenant")
+	chatID := chi.URLParam(r, "chat")
+
+	msgs, err := h.repo.Messages(r.Context(), tenantID, chatID, messagesLimit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "dashboard: messages error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	runs, err := h.runs.ByChat(r.Context(), tenantID, chatID, runsLimit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "dashboard: runs error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]runView, 0, len(runs))
+	for _, run := range runs {
+		views = append(views, newRunView(run))
+	}
+
+	h.render(w, "chat.html", map[string]any{
+		"Tenant":   tenantID,
+		"Chat":     chatID,
+		"Messages": msgs,
+		"Runs":     views,
+	})
+}
+
+// Feedback — POST /admin/ui/runs/{id}/feedback: отметка «верно/неверно» с комментарием
+func (h *Handler) Feedback(w http.ResponseWriter, r *http.Request) {
+	// basic auth браузер подставляет сам — чужая страница не должна постить форму
+	if !sameOrigin(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	fb := &pipeline.Feedback{
+		RunID:   id,
+		Verdict: pipeline.Verdict(r.PostFormValue("verdict")),
+		Comment: strings.TrimSpace(r.PostFormValue("comment")),
+		Author:  httpx.AdminUser(r),
+	}
+	if !fb.Verdict.Valid() {
+		http.Error(w, "verdict must be correct or incorrect", http.StatusBadRequest)
+		return
+	}
+	if len(fb.Comment) > commentMaxLen {
+		http.Error(w, "comment too long", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.runs.AddFeedback(r.Context(), fb); err != nil {
+		if errors.Is(err, pipeline.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "dashboard: feedback error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "dashboard: run marked", "run_id", fb.RunID, "verdict", fb.Verdict, "by", fb.Author)
+
+	back := "/admin/ui/"
+	if t, c := r.PostFormValue("tenant"), r.PostFormValue("chat"); t != "" && c != "" {
+		back = "/admin/ui/chats/" + url.PathEscape(t) + "/" + url.PathEscape(c) + "#run-" + strconv.FormatInt(id, 10)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func (h *Handler) render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, name, data); err != nil {
+		slog.Error("dashboard: render error", "template", name, "err", err)
+	}
+}
+
+// sameOrigin — Origin (или Referer) запроса совпадает с хостом панели
+func sameOrigin(r *http.Request) bool {
+	src := r.Header.Get("Origin")
+	if src == "" {
+		src = r.Header.Get("Referer")
+	}
+	if src == "" {
+		return false
+	}
+	u, err := url.Parse(src)
+	return err == nil && u.Host == r.Host
+}
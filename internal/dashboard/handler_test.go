@@ -0,0 +1,67 @@
+package dashboard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/pipeline"
+)
+
+// feedbackRuns — запоминает разметку
+type feedbackRuns struct {
+	pipeline.Repo
+	got *pipeline.Feedback
+}
+
+func (r *feedbackRuns) AddFeedback(_ context.Context, fb *pipeline.Feedback) error {
+	r.got = fb
+	return nil
+}
+
+func TestFeedbackAuthor(t *testing.T) {
+	tests := []struct {
+		name   string
+		login  string
+		header string
+		want   string
+	}{
+		{"basic auth login", "alice", "", "alice"},
+		{"spoofed header ignored", "alice", "bob", "alice"},
+		{"no login", "", "bob", "admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := &feedbackRuns{}
+			h := NewHandler(nil, runs)
+			router := chi.NewRouter()
+			router.Post("/admin/ui/runs/{id}/feedback", h.Feedback)
+
+			form := url.Values{"verdict": {string(pipeline.VerdictCorrect)}, "comment": {"ок"}}
+			r := httptest.NewRequest(http.MethodPost, "/admin/ui/runs/7/feedback", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set("Origin", "http://"+r.Host)
+			if tt.login != "" {
+				r.SetBasicAuth(tt.login, "token")
+			}
+			if tt.header != "" {
+				r.Header.Set("X-Admin-User", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != http.StatusSeeOther {
+				t.Fatalf("status %d, want 303: %s", w.Code, w.Body)
+			}
+			if runs.got == nil || runs.got.RunID != 7 || runs.got.Author != tt.want {
+				t.Errorf("feedback = %+v, want run 7 by %q", runs.got, tt.want)
+			}
+		})
+	}
+}
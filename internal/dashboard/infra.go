@@ -0,0 +1,78 @@
+package dashboard
+
+import (
+	"context"
+	"database/sql"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+func (r *repo) Conversations(ctx context.Context, tenantID string, limit int) ([]Conversation, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT c.tenant_id, c.chat_id, c.n, l.text, c.last_at
+		FROM (
+			SELECT tenant_id, chat_id, count(*) AS n, max(created_at) AS last_at
+			FROM messages
+			WHERE $1 = '' OR tenant_id = $1
+			GROUP BY tenant_id, chat_id
+			ORDER BY last_at DESC
+			LIMIT $2
+		) c
+		CROSS JOIN LATERAL (
+			SELECT text FROM messages m
+			WHERE m.tenant_id = c.tenant_id AND m.chat_id = c.chat_id
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT 1
+		) l
+		ORDER BY c.last_at DESC
+	`, tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Conversation
+	for rows.Next() {
+		var c Conversation
+		if err := rows.Scan(&c.TenantID, &c.ChatID, &c.Messages, &c.LastText, &c.LastAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *repo) Messages(ctx context.Context, tenantID, chatID string, limit int) ([]ChatMessage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, sender, text, created_at, delivery_status, delivery_error FROM (
+			SELECT id, sender, text, created_at,
+				COALESCE(delivery_status, '') AS delivery_status,
+				COALESCE(delivery_error, '') AS delivery_error
+			FROM messages
+			WHERE tenant_id = $1 AND chat_id = $2
+			ORDER BY created_at DESC, id DESC
+			LIMIT $3
+		) last
+		ORDER BY created_at, id
+	`, tenantID, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ChatMessage
+	for rows.Next() {
+		var m ChatMessage
+		if err := rows.Scan(&m.ID, &m.Sender, &m.Text, &m.CreatedAt, &m.DeliveryStatus, &m.DeliveryError); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
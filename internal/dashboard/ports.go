@@ -0,0 +1,33 @@
+package dashboard
+
+import (
+	"context"
+	"time"
+)
+
+// Conversation — строка списка диалогов
+type Conversation struct {
+	TenantID string
+	ChatID   string
+	Messages int
+	LastText string
+	LastAt   time.Time
+}
+
+// ChatMessage — сообщение в ленте диалога
+type ChatMessage struct {
+	ID        int64
+	Sender    string
+	Text      string
+	CreatedAt time.Time
+	// DeliveryStatus — pending / sent / failed для ответов AI, "" — не отправлялось
+	DeliveryStatus string
+	DeliveryError  string
+}
+
+// Repo — чтение диалогов из messages для панели
+type Repo interface {
+	// Conversations — последние по активности чаты; tenantID == "" — все тенанты
+	Conversations(ctx context.Context, tenantID string, limit int) ([]Conversation, error)
+	Messages(ctx context.Context, tenantID, chatID string, limit int) ([]ChatMessage, error)
+}
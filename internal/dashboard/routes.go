@@ -0,0 +1,19 @@
+package dashboard
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterAdminRoutes — пути относительно /admin
+func RegisterAdminRoutes(r chi.Router, h *Handler) {
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/admin/ui/", http.StatusFound)
+	})
+	r.Route("/ui", func(r chi.Router) {
+		r.Get("/", h.Index)
+		r.Get("/chats/{tenant}/{chat}", h.Chat)
+		r.Post("/runs/{id}/feedback", h.Feedback)
+	})
+}
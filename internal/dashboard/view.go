@@ -0,0 +1,61 @@
+package dashboard
+
+import (
+	"encoding/json"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/ai"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/pipeline"
+)
+
+// runView — прогон глазами оператора: что выбрано, что решили валидаторы, что ушло бы клиенту
+type runView struct {
+	pipeline.Run
+
+	Facts         []string
+	SelectorMode  string
+	FactVerdict   string
+	Draft         string
+	BuilderMode   string
+	AnswerVerdict string
+
+	Tokens    int
+	CostUSD   float64
+	LatencyMs int64
+}
+
+// stageResult — общий вид разобранных ответов этапов
+type stageResult struct {
+	Facts  []string `json:"facts"`
+	Mode   string   `json:"mode"`
+	Answer string   `json:"answer"`
+}
+
+func newRunView(run pipeline.Run) runView {
+	v := runView{Run: run}
+
+	for _, s := range run.Steps {
+		v.Tokens += s.TotalTokens
+		v.CostUSD += s.CostUSD
+		v.LatencyMs += s.LatencyMs
+
+		var res stageResult
+		switch {
+		case s.Parsed != nil:
+			_ = json.Unmarshal(s.Parsed, &res)
+		case s.Error != "":
+			res.Mode = "ERROR: " + s.Error
+		}
+
+		switch s.Stage {
+		case ai.StageFactSelector:
+			v.Facts, v.SelectorMode = res.Facts, res.Mode
+		case ai.StageFactValidator:
+			v.FactVerdict = res.Mode
+		case ai.StageAnswerBuilder:
+			v.Draft, v.BuilderMode = res.Answer, res.Mode
+		case ai.StageAnswerValidator:
+			v.AnswerVerdict = res.Mode
+		}
+	}
+	return v
+}
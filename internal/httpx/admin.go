@@ -0,0 +1,47 @@
+package httpx
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// AdminOnly — доступ по ADMIN_TOKEN: "Authorization: Bearer <token>"
+// или basic auth с токеном в качестве пароля (для браузера).
+// Пустой токен закрывает доступ полностью.
+func AdminOnly(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" || !checkToken(r, token) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func checkToken(r *http.Request, token string) bool {
+	var got string
+
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		got = strings.TrimSpace(bearer)
+	} else if _, pass, ok := r.BasicAuth(); ok {
+		got = pass
+	}
+
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// AdminUser — кто вносит правку; для истории ревизий и разметки прогонов.
+// Только логин basic auth, который прошёл AdminOnly вместе с токеном;
+// свободным заголовкам (X-Admin-User) не верим — подставить их может кто угодно
+func AdminUser(r *http.Request) string {
+	if u, _, ok := r.BasicAuth(); ok {
+		if u = strings.TrimSpace(u); u != "" {
+			return u
+		}
+	}
+	return "admin"
+}
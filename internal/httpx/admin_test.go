@@ -0,0 +1,69 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		basic  string // логин basic auth; "" — без basic auth
+		header string // X-Admin-User
+		bearer bool
+		want   string
+	}{
+		{"basic auth login", "alice", "", false, "alice"},
+		{"header ignored", "alice", "mallory", false, "alice"},
+		{"header alone ignored", "", "mallory", false, "admin"},
+		{"bearer token", "", "", true, "admin"},
+		{"blank login", "  ", "", false, "admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.basic != "" {
+				r.SetBasicAuth(tt.basic, "token")
+			}
+			if tt.bearer {
+				r.Header.Set("Authorization", "Bearer token")
+			}
+			if tt.header != "" {
+				r.Header.Set("X-Admin-User", tt.header)
+			}
+			if got := AdminUser(r); got != tt.want {
+				t.Errorf("AdminUser = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		auth  func(r *http.Request)
+		want  int
+	}{
+		{"bearer", "secret", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		{"basic auth password", "secret", func(r *http.Request) { r.SetBasicAuth("alice", "secret") }, http.StatusOK},
+		{"wrong token", "secret", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"no credentials", "secret", func(*http.Request) {}, http.StatusUnauthorized},
+		{"empty token closes access", "", func(r *http.Request) { r.Header.Set("Authorization", "Bearer ") }, http.StatusUnauthorized},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			tt.auth(r)
+			w := httptest.NewRecorder()
+			AdminOnly(tt.token)(ok).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package httpx
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState — состояние автомата
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // всё ходит
+	BreakerOpen     BreakerState = "open"      // внешний сервис лежит, запросы не шлём
+	BreakerHalfOpen BreakerState = "half_open" // пробный запрос после паузы
+)
+
+// Breaker — circuit breaker: после threshold сбоев подряд пауза cooldown,
+// затем один пробный запрос решает, открываться снова или работать дальше
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow — можно ли слать запрос сейчас
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// Success — сервис ответил (пусть даже 4xx)
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	b.failures = 0
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// Failure — сбой на стороне сервиса (5xx, сеть)
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.probing || b.failures >= b.threshold {
+		b.failures = max(b.failures, b.threshold)
+		b.openUntil = time.Now().Add(b.cooldown)
+		b.probing = false
+	}
+}
+
+// Release — запрос отменён до ответа: о сервисе ничего не узнали,
+// пробу может сделать следующий запрос
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.failures < b.threshold:
+		return BreakerClosed
+	case time.Now().Before(b.openUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
@@ -0,0 +1,46 @@
+package httpx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	tests := []struct {
+		name      string
+		finish    func(b *Breaker)
+		wantState BreakerState
+		wantAllow bool
+	}{
+		{"successful probe closes", (*Breaker).Success, BreakerClosed, true},
+		{"failed probe opens again", (*Breaker).Failure, BreakerOpen, false},
+		{"cancelled probe lets the next request probe", (*Breaker).Release, BreakerHalfOpen, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker(2, 10*time.Millisecond)
+			b.Failure()
+			b.Failure()
+			if b.Allow() {
+				t.Fatal("open breaker allowed a request")
+			}
+
+			time.Sleep(20 * time.Millisecond)
+			if !b.Allow() {
+				t.Fatal("half-open breaker refused the probe")
+			}
+			if b.Allow() {
+				t.Fatal("second request allowed while probing")
+			}
+
+			tt.finish(b)
+			if got := b.State(); got != tt.wantState {
+				t.Errorf("state = %s, want %s", got, tt.wantState)
+			}
+			if got := b.Allow(); got != tt.wantAllow {
+				t.Errorf("Allow = %v, want %v", got, tt.wantAllow)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package httpx
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FailureLimiter — считает неудачные попытки по IP;
+// после max неудач за window адрес блокируется до конца окна
+type FailureLimiter struct {
+	max    int
+	window time.Duration
+
+	mu    sync.Mutex
+	byKey map[string]*failures
+}
+
+type failures struct {
+	count int
+	since time.Time
+}
+
+func NewFailureLimiter(max int, window time.Duration) *FailureLimiter {
+	return &FailureLimiter{
+		max:    max,
+		window: window,
+		byKey:  map[string]*failures{},
+	}
+}
+
+// Blocked — адрес исчерпал лимит неудач в текущем окне
+func (l *FailureLimiter) Blocked(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.byKey[key]
+	if !ok {
+		return false
+	}
+	if time.Since(f.since) > l.window {
+		delete(l.byKey, key)
+		return false
+	}
+	return f.count >= l.max
+}
+
+// Fail — зафиксировать неудачу; возвращает число неудач в окне
+func (l *FailureLimiter) Fail(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.gc(now)
+
+	f, ok := l.byKey[key]
+	if !ok || now.Sub(f.since) > l.window {
+		f = &failures{since: now}
+		l.byKey[key] = f
+	}
+	f.count++
+	return f.count
+}
+
+// gc — чистим протухшие окна, чтобы карта не росла от сканеров
+func (l *FailureLimiter) gc(now time.Time) {
+	if len(l.byKey) < 1024 {
+		return
+	}
+	for k, f := range l.byKey {
+		if now.Sub(f.since) > l.window {
+			delete(l.byKey, k)
+		}
+	}
+}
+
+// ClientIP — адрес TCP-соединения; заголовкам прокси не доверяем,
+// иначе лимит обходится подделкой X-Forwarded-For
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
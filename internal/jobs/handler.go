@@ -0,0 +1,21 @@
+package jobs
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// DepthHandler — GET /admin/queue
+func DepthHandler(q Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d, err := q.Depth(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "jobs: depth error", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(d)
+	}
+}
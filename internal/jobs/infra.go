@@ -0,0 +1,293 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type queue struct {
+	db *sql.DB
+}
+
+func NewQueue(db *sql.DB) Queue {
+	return &queue{db: db}
+}
+
+func (q *queue) Enqueue(ctx context.Context, job *Job) error {
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+	var dedupKey *string
+	if job.DedupKey != "" {
+		dedupKey = &job.DedupKey
+	}
+
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO jobs (tenant_id, chat_id, kind, payload, max_attempts, dedup_key, debounce_ms, run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, now() + make_interval(secs => $8))
+		ON CONFLICT (tenant_id, dedup_key) WHERE dedup_key IS NOT NULL DO NOTHING
+		RETURNING id, status, run_at
+	`,
+		job.TenantID,
+		job.ChatID,
+		job.Kind,
+		[]byte(job.Payload),
+		job.MaxAttempts,
+		dedupKey,
+		job.Debounce.Milliseconds(),
+		job.Debounce.Seconds(),
+	).Scan(&job.ID, &job.Status, &job.RunAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+
+	// клиент ещё пишет — окно всех ждущих задач чата начинается заново
+	if job.Debounce > 0 {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE jobs SET run_at = $5, updated_at = now()
+			WHERE tenant_id = $1 AND chat_id = $2 AND kind = $3 AND debounce_ms > 0
+				AND status = 'pending' AND id < $4 AND run_at < $5
+		`, job.TenantID, job.ChatID, job.Kind, job.ID, job.RunAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const jobColumns = `id, tenant_id, chat_id, kind, payload, status, attempts, max_attempts, run_at, last_error, debounce_ms`
+
+func scanJob(sc interface{ Scan(dest ...any) error }) (*Job, error) {
+	var j Job
+	var payload []byte
+	var debounceMs int64
+	if err := sc.Scan(
+		&j.ID,
+		&j.TenantID,
+		&j.ChatID,
+		&j.Kind,
+		&payload,
+		&j.Status,
+		&j.Attempts,
+		&j.MaxAttempts,
+		&j.RunAt,
+		&j.LastError,
+		&debounceMs,
+	); err != nil {
+		return nil, err
+	}
+	j.Payload = payload
+	j.Debounce = time.Duration(debounceMs) * time.Millisecond
+	return &j, nil
+}
+
+func (q *queue) Claim(ctx context.Context) (*Job, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	j, err := scanJob(tx.QueryRowContext(ctx, `
+		UPDATE jobs SET
+			status = 'running',
+			attempts = attempts + 1,
+			locked_at = now(),
+			updated_at = now()
+		WHERE id = (
+			SELECT j.id FROM jobs j
+			WHERE j.status = 'pending' AND j.run_at <= now()
+				-- чат уже обрабатывается другим воркером
+				AND NOT EXISTS (
+					SELECT 1 FROM jobs r
+					WHERE r.tenant_id = j.tenant_id AND r.chat_id = j.chat_id
+						AND r.status = 'running'
+				)
+				-- в чате есть более ранняя задача (в т.ч. ждущая повтора)
+				AND NOT EXISTS (
+					SELECT 1 FROM jobs e
+					WHERE e.tenant_id = j.tenant_id AND e.chat_id = j.chat_id
+						AND e.status = 'pending' AND e.id < j.id
+				)
+			ORDER BY j.run_at, j.id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING `+jobColumns))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if j.Debounce > 0 {
+		if j.Batch, err = claimBatch(ctx, tx, j); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// claimBatch — остальные готовые задачи с окном того же чата и вида, по порядку
+func claimBatch(ctx context.Context, tx *sql.Tx, head *Job) ([]*Job, error) {
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE jobs SET
+			status = 'running',
+			attempts = attempts + 1,
+			locked_at = now(),
+			updated_at = now()
+		WHERE tenant_id = $1 AND chat_id = $2 AND kind = $3 AND debounce_ms > 0
+			AND status = 'pending' AND run_at <= now() AND id > $4
+		RETURNING `+jobColumns,
+		head.TenantID, head.ChatID, head.Kind, head.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(batch, func(a, b int) bool { return batch[a].ID < batch[b].ID })
+	return batch, nil
+}
+
+func (q *queue) Complete(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, `
+		UPDATE jobs SET status = 'done', locked_at = NULL, updated_at = now()
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+func (q *queue) Retry(ctx context.Context, id int64, runAt time.Time, lastErr string) error {
+	_, err := q.db.ExecContext(ctx, `
+		UPDATE jobs SET status = 'pending', run_at = $2, last_error = $3, locked_at = NULL, updated_at = now()
+		WHERE id = $1
+	`, id, runAt, lastErr)
+	return err
+}
+
+func (q *queue) Fail(ctx context.Context, id int64, lastErr string) error {
+	_, err := q.db.ExecContext(ctx, `
+		UPDATE jobs SET status = 'dead', last_error = $2, locked_at = NULL, updated_at = now()
+		WHERE id = $1
+	`, id, lastErr)
+	return err
+}
+
+func (q *queue) Superseded(ctx context.Context, job *Job) (bool, error) {
+	ids := job.IDs()
+	var ok bool
+	err := q.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM jobs
+			WHERE tenant_id = $1 AND chat_id = $2 AND kind = $3 AND debounce_ms > 0
+				AND status = 'pending' AND id > $4
+		)
+	`, job.TenantID, job.ChatID, job.Kind, ids[len(ids)-1]).Scan(&ok)
+	return ok, err
+}
+
+func (q *queue) Supersede(ctx context.Context, job *Job) error {
+	// run_at — окно самой новой ждущей задачи: пачка уйдёт вместе с ней
+	_, err := q.db.ExecContext(ctx, `
+		UPDATE jobs SET
+			status = 'pending',
+			attempts = GREATEST(attempts - 1, 0),
+			last_error = 'superseded by a newer job',
+			run_at = COALESCE((
+				SELECT max(n.run_at) FROM jobs n
+				WHERE n.tenant_id = $2 AND n.chat_id = $3 AND n.kind = $4
+					AND n.debounce_ms > 0 AND n.status = 'pending'
+			), now()),
+			locked_at = NULL,
+			updated_at = now()
+		WHERE id = ANY($1)
+	`, pq.Array(job.IDs()), job.TenantID, job.ChatID, job.Kind)
+	return err
+}
+
+func (q *queue) RequeueStale(ctx context.Context, olderThan time.Duration) (int, error) {
+	res, err := q.db.ExecContext(ctx, `
+		UPDATE jobs SET status = 'pending', locked_at = NULL, updated_at = now()
+		WHERE status = 'running' AND locked_at < now() - make_interval(secs => $1)
+	`, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (q *queue) PruneDone(ctx context.Context, olderThan time.Duration) (int, error) {
+	res, err := q.db.ExecContext(ctx, `
+		DELETE FROM jobs
+		WHERE status = 'done' AND updated_at < now() - make_interval(secs => $1)
+	`, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (q *queue) Depth(ctx context.Context) (Depth, error) {
+	d := Depth{ByStatus: map[Status]int{}}
+
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT status, count(*) FROM jobs GROUP BY status
+	`)
+	if err != nil {
+		return d, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var st Status
+		var n int
+		if err := rows.Scan(&st, &n); err != nil {
+			return d, err
+		}
+		d.ByStatus[st] = n
+	}
+	if err := rows.Err(); err != nil {
+		return d, err
+	}
+
+	err = q.db.QueryRowContext(ctx, `
+		SELECT COALESCE(extract(epoch from now() - min(created_at)), 0)::float8
+		FROM jobs
+		WHERE status = 'pending'
+	`).Scan(&d.OldestPending)
+	return d, err
+}
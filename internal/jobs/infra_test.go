@@ -0,0 +1,172 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/dbtest"
+)
+
+func enqueue(t *testing.T, q Queue, chatID string, debounce time.Duration) *Job {
+	t.Helper()
+	j := &Job{TenantID: "t", ChatID: chatID, Kind: "test", Payload: []byte(`{}`), Debounce: debounce}
+	if err := q.Enqueue(context.Background(), j); err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func claimID(t *testing.T, q Queue) int64 {
+	t.Helper()
+	j, err := q.Claim(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j == nil {
+		return 0
+	}
+	return j.ID
+}
+
+func TestClaimChatOrder(t *testing.T) {
+	q := NewQueue(dbtest.Open(t))
+	ctx := context.Background()
+
+	a := enqueue(t, q, "chat1", 0)
+	b := enqueue(t, q, "chat1", 0)
+	c := enqueue(t, q, "chat2", 0)
+
+	steps := []struct {
+		name string
+		want int64
+	}{
+		{"first job of chat1", a.ID},
+		{"chat1 busy, chat2 goes in parallel", c.ID},
+		{"nothing left while chat1 runs", 0},
+	}
+	for _, s := range steps {
+		if got := claimID(t, q); got != s.want {
+			t.Fatalf("%s: claimed %d, want %d", s.name, got, s.want)
+		}
+	}
+
+	// повтор задачи держит чат: следующая не обгоняет ждущую повтора
+	if err := q.Retry(ctx, a.ID, time.Now().Add(time.Hour), "boom"); err != nil {
+		t.Fatal(err)
+	}
+	if got := claimID(t, q); got != 0 {
+		t.Fatalf("job %d overtook a retrying job of the same chat", got)
+	}
+
+	if err := q.Retry(ctx, a.ID, time.Now(), "boom"); err != nil {
+		t.Fatal(err)
+	}
+	if got := claimID(t, q); got != a.ID {
+		t.Fatalf("claimed %d, want retried %d", got, a.ID)
+	}
+	if err := q.Complete(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := claimID(t, q); got != b.ID {
+		t.Fatalf("claimed %d, want %d", got, b.ID)
+	}
+}
+
+func TestClaimDebounceBatch(t *testing.T) {
+	db := dbtest.Open(t)
+	q := NewQueue(db)
+
+	const window = 300 * time.Millisecond
+	first := enqueue(t, q, "chat1", window)
+	time.Sleep(window / 2)
+	second := enqueue(t, q, "chat1", window)
+	other := enqueue(t, q, "chat2", 0)
+
+	// новое сообщение сдвинуло окно первого
+	var runAt time.Time
+	if err := db.QueryRow(`SELECT run_at FROM jobs WHERE id = $1`, first.ID).Scan(&runAt); err != nil {
+		t.Fatal(err)
+	}
+	if !runAt.Equal(second.RunAt) {
+		t.Errorf("first run_at = %v, want moved to %v", runAt, second.RunAt)
+	}
+
+	if got := claimID(t, q); got != other.ID {
+		t.Fatalf("claimed %d, want job without window %d", got, other.ID)
+	}
+	if got := claimID(t, q); got != 0 {
+		t.Fatalf("claimed %d inside the window", got)
+	}
+
+	time.Sleep(window)
+	j, err := q.Claim(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j == nil || j.ID != first.ID {
+		t.Fatalf("claimed %+v, want head %d", j, first.ID)
+	}
+	if j.Debounce != window {
+		t.Errorf("debounce = %v, want %v", j.Debounce, window)
+	}
+	if len(j.Batch) != 1 || j.Batch[0].ID != second.ID {
+		t.Fatalf("batch = %v, want [%d]", j.IDs(), second.ID)
+	}
+	if j.Batch[0].Attempts != 1 {
+		t.Errorf("batch attempts = %d, want 1", j.Batch[0].Attempts)
+	}
+}
+
+func TestSupersedeJoinsNextBatch(t *testing.T) {
+	db := dbtest.Open(t)
+	q := NewQueue(db)
+	ctx := context.Background()
+
+	const window = 200 * time.Millisecond
+	first := enqueue(t, q, "chat1", window)
+	time.Sleep(window + window/2)
+
+	running, err := q.Claim(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running == nil || running.ID != first.ID {
+		t.Fatalf("claimed %+v, want %d", running, first.ID)
+	}
+	if ok, err := q.Superseded(ctx, running); err != nil || ok {
+		t.Fatalf("Superseded = %v %v before a new message", ok, err)
+	}
+
+	// клиент пишет, пока пачка в работе
+	second := enqueue(t, q, "chat1", window)
+	if ok, err := q.Superseded(ctx, running); err != nil || !ok {
+		t.Fatalf("Superseded = %v %v, want true", ok, err)
+	}
+	if err := q.Supersede(ctx, running); err != nil {
+		t.Fatal(err)
+	}
+
+	var runAt time.Time
+	if err := db.QueryRow(`SELECT run_at FROM jobs WHERE id = $1`, first.ID).Scan(&runAt); err != nil {
+		t.Fatal(err)
+	}
+	if !runAt.Equal(second.RunAt) {
+		t.Errorf("superseded run_at = %v, want the new window %v", runAt, second.RunAt)
+	}
+
+	time.Sleep(window + window/2)
+	j, err := q.Claim(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j == nil || j.ID != first.ID || len(j.Batch) != 1 || j.Batch[0].ID != second.ID {
+		t.Fatalf("claimed %+v, want %d with batch [%d]", j, first.ID, second.ID)
+	}
+	if j.Attempts != 1 || !j.Retried() {
+		t.Errorf("attempts = %d retried = %v, want 1 true: supersede is not a failed attempt", j.Attempts, j.Retried())
+	}
+	if j.Batch[0].Retried() {
+		t.Error("new job must not look retried")
+	}
+}
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPermanent — повтор не поможет (битый payload и т.п.)
+	ErrPermanent = errors.New("permanent job error")
+	// ErrDuplicate — задача с таким DedupKey уже есть
+	ErrDuplicate = errors.New("duplicate job")
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusDead    Status = "dead" // попытки исчерпаны
+)
+
+type Job struct {
+	ID          int64
+	TenantID    string
+	ChatID      string
+	Kind        string
+	Payload     json.RawMessage
+	DedupKey    string // пусто — без дедупликации
+	Status      Status
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LastError   string
+
+	// Debounce — окно тишины: задача ждёт Debounce после последней задачи того же
+	// чата и вида и выполняется вместе с ними одной пачкой (Batch); 0 — сразу и одна
+	Debounce time.Duration
+	// Batch — задачи, взятые вместе с этой, по порядку поступления; итог у пачки общий
+	Batch []*Job
+}
+
+// IDs — id задачи и всей её пачки
+func (j *Job) IDs() []int64 {
+	ids := []int64{j.ID}
+	for _, b := range j.Batch {
+		ids = append(ids, b.ID)
+	}
+	return ids
+}
+
+// Retried — задачу уже брали в работу: повтор после ошибки, рестарт
+// или возврат пачки в окно из-за нового сообщения (Supersede)
+func (j *Job) Retried() bool {
+	return j.Attempts > 1 || j.LastError != ""
+}
+
+// Depth — состояние очереди
+type Depth struct {
+	ByStatus      map[Status]int `json:"by_status"`
+	OldestPending float64        `json:"oldest_pending_seconds"`
+}
+
+// Queue — persistence очереди
+type Queue interface {
+	// Enqueue — ErrDuplicate, если задача с тем же DedupKey уже ставилась.
+	// Задача с Debounce сдвигает run_at ждущих задач с окном того же чата и вида.
+	Enqueue(ctx context.Context, job *Job) error
+	// Claim — взять одну готовую задачу; nil, nil — задач нет.
+	// Задачи одного чата выдаются строго по одной и в порядке поступления,
+	// разные чаты идут параллельно. Задача с окном тишины забирает с собой
+	// остальные готовые задачи с окном того же чата и вида (Batch).
+	Claim(ctx context.Context) (*Job, error)
+	Complete(ctx context.Context, id int64) error
+	Retry(ctx context.Context, id int64, runAt time.Time, lastErr string) error
+	Fail(ctx context.Context, id int64, lastErr string) error
+
+	// Superseded — у чата задачи с окном появилась новая ждущая задача того же вида
+	Superseded(ctx context.Context, job *Job) (bool, error)
+	// Supersede — вернуть выполняемую пачку в ожидание: она уйдёт следующей пачкой
+	// вместе с новыми задачами чата, попытка не засчитывается
+	Supersede(ctx context.Context, job *Job) error
+
+	// RequeueStale — вернуть в pending задачи, зависшие в running (упавший процесс)
+	RequeueStale(ctx context.Context, olderThan time.Duration) (int, error)
+	PruneDone(ctx context.Context, olderThan time.Duration) (int, error)
+	Depth(ctx context.Context) (Depth, error)
+}
+
+// HandlerFunc — обработка одной задачи; ошибка — повтор с backoff
+type HandlerFunc func(ctx context.Context, job *Job) error
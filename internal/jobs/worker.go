@@ -0,0 +1,253 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/logx"
+)
+
+const (
+	DefaultMaxAttempts = 8
+
+	pollInterval  = time.Second
+	backoffBase   = 5 * time.Second
+	backoffMax    = 10 * time.Minute
+	staleAfter    = 10 * time.Minute // дольше этого задача не выполняется
+	keepDone      = 72 * time.Hour
+	housekeepTick = time.Minute
+)
+
+// Pool — воркеры, разбирающие очередь
+type Pool struct {
+	queue   Queue
+	handle  HandlerFunc
+	workers int
+	// watchEvery — как часто пачка с окном проверяет, не написал ли клиент ещё
+	watchEvery time.Duration
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+func NewPool(queue Queue, handle HandlerFunc, workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{queue: queue, handle: handle, workers: workers, watchEvery: pollInterval}
+}
+
+// Start — поднимает задачи, брошенные прошлым процессом, и запускает воркеров.
+// Бридж работает одним инстансом, поэтому всё running на старте — наследство упавшего процесса.
+func (p *Pool) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	if n, err := p.queue.RequeueStale(ctx, 0); err != nil {
+		slog.ErrorContext(ctx, "jobs: requeue on start failed", "err", err)
+	} else if n > 0 {
+		slog.InfoContext(ctx, "jobs: resumed after restart", "count", n)
+	}
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.loop(ctx)
+	}
+
+	p.wg.Add(1)
+	go p.housekeep(ctx)
+}
+
+// Stop — дожидается текущих задач
+func (p *Pool) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+func (p *Pool) loop(ctx context.Context) {
+	defer p.wg.Done()
+
+	for {
+		job, err := p.queue.Claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "jobs: claim failed", "err", err)
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		p.run(ctx, job)
+	}
+}
+
+func (p *Pool) run(ctx context.Context, job *Job) {
+	ctx = logx.With(ctx, "job_id", job.ID, "job_kind", job.Kind)
+	if len(job.Batch) > 0 {
+		ctx = logx.With(ctx, "batch", len(job.Batch)+1)
+	}
+	runCtx, stopWatch := p.watch(ctx, job)
+	err := p.safeHandle(runCtx, job)
+	superseded := stopWatch()
+
+	// статус пишем и при остановке процесса — отдельным контекстом
+	dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// итог пачки общий: задачи одного окна завершаются и повторяются вместе
+	ids := job.IDs()
+
+	// остановка процесса посреди задачи — вернуть в очередь как есть
+	if ctx.Err() != nil {
+		for _, id := range ids {
+			if err := p.queue.Retry(dbCtx, id, time.Now(), "interrupted by shutdown"); err != nil {
+				slog.ErrorContext(ctx, "jobs: requeue failed", "id", id, "err", err)
+			}
+		}
+		return
+	}
+
+	// клиент написал посреди прогона — пачка уходит в следующее окно вместе с новым сообщением
+	if err != nil && superseded {
+		slog.InfoContext(ctx, "jobs: batch superseded by a newer job", "err", err)
+		if err := p.queue.Supersede(dbCtx, job); err != nil {
+			slog.ErrorContext(ctx, "jobs: supersede failed", "err", err)
+		}
+		return
+	}
+
+	if err == nil {
+		for _, id := range ids {
+			if err := p.queue.Complete(dbCtx, id); err != nil {
+				slog.ErrorContext(ctx, "jobs: complete failed", "id", id, "err", err)
+			}
+		}
+		return
+	}
+
+	if job.Attempts >= job.MaxAttempts || errors.Is(err, ErrPermanent) {
+		slog.ErrorContext(ctx, "jobs: job dead", "attempts", job.Attempts, "err", err)
+		for _, id := range ids {
+			if err := p.queue.Fail(dbCtx, id, err.Error()); err != nil {
+				slog.ErrorContext(ctx, "jobs: fail failed", "id", id, "err", err)
+			}
+		}
+		return
+	}
+
+	delay := Backoff(job.Attempts)
+	runAt := time.Now().Add(delay)
+	slog.WarnContext(ctx, "jobs: job failed, retrying", "attempt", job.Attempts, "delay", delay.String(), "err", err)
+	for _, id := range ids {
+		if err := p.queue.Retry(dbCtx, id, runAt, err.Error()); err != nil {
+			slog.ErrorContext(ctx, "jobs: retry failed", "id", id, "err", err)
+		}
+	}
+}
+
+// watch — пока выполняется задача с окном, следит за новыми задачами того же
+// чата и отменяет выполнение, если клиент написал ещё. Возвращённая функция
+// останавливает слежение и говорит, была ли отмена
+func (p *Pool) watch(ctx context.Context, job *Job) (context.Context, func() bool) {
+	if job.Debounce <= 0 {
+		return ctx, func() bool { return false }
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var superseded atomic.Bool
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		t := time.NewTicker(p.watchEvery)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			ok, err := p.queue.Superseded(ctx, job)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.WarnContext(ctx, "jobs: superseded check failed", "err", err)
+				}
+				continue
+			}
+			if ok {
+				superseded.Store(true)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return ctx, func() bool {
+		cancel()
+		<-done
+		return superseded.Load()
+	}
+}
+
+func (p *Pool) safeHandle(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return p.handle(ctx, job)
+}
+
+func (p *Pool) housekeep(ctx context.Context) {
+	defer p.wg.Done()
+
+	t := time.NewTicker(housekeepTick)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		if n, err := p.queue.RequeueStale(ctx, staleAfter); err != nil {
+			slog.ErrorContext(ctx, "jobs: requeue stale failed", "err", err)
+		} else if n > 0 {
+			slog.WarnContext(ctx, "jobs: requeued stale jobs", "count", n)
+		}
+
+		if _, err := p.queue.PruneDone(ctx, keepDone); err != nil {
+			slog.ErrorContext(ctx, "jobs: prune failed", "err", err)
+		}
+	}
+}
+
+// Backoff — экспоненциальная задержка с джиттером: 5s, 10s, 20s … до 10m
+func Backoff(attempt int) time.Duration {
+	d := backoffBase
+	for i := 1; i < attempt && d < backoffMax; i++ {
+		d *= 2
+	}
+	if d > backoffMax {
+		d = backoffMax
+	}
+	// ±20%
+	jitter := time.Duration(rand.Int63n(int64(d)/5*2+1)) - d/5
+	return d + jitter
+}
@@ -0,0 +1,196 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordQueue — запоминает, чем закончилась каждая задача
+type recordQueue struct {
+	Queue // остальное пулу в run не нужно
+
+	mu     sync.Mutex
+	result map[int64]string
+
+	newer bool // у чата есть задача новее выполняемой пачки
+}
+
+func (q *recordQueue) set(id int64, r string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.result[id] = r
+	return nil
+}
+
+func (q *recordQueue) Complete(_ context.Context, id int64) error { return q.set(id, "done") }
+func (q *recordQueue) Fail(_ context.Context, id int64, _ string) error {
+	return q.set(id, "dead")
+}
+func (q *recordQueue) Retry(_ context.Context, id int64, _ time.Time, _ string) error {
+	return q.set(id, "retry")
+}
+
+func (q *recordQueue) Superseded(context.Context, *Job) (bool, error) { return q.newer, nil }
+func (q *recordQueue) Supersede(_ context.Context, job *Job) error {
+	for _, id := range job.IDs() {
+		q.set(id, "superseded")
+	}
+	return nil
+}
+
+func TestPoolRunBatchSharesResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+		want     string
+	}{
+		{"success completes the whole batch", nil, 1, "done"},
+		{"error retries the whole batch", errors.New("boom"), 1, "retry"},
+		{"permanent error kills the whole batch", ErrPermanent, 1, "dead"},
+		{"last attempt kills the whole batch", errors.New("boom"), DefaultMaxAttempts, "dead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &recordQueue{result: map[int64]string{}}
+			p := NewPool(q, func(context.Context, *Job) error { return tt.err }, 1)
+
+			job := &Job{
+				ID:          1,
+				Attempts:    tt.attempts,
+				MaxAttempts: DefaultMaxAttempts,
+				Debounce:    time.Second,
+				Batch:       []*Job{{ID: 2}, {ID: 3}},
+			}
+			p.run(context.Background(), job)
+
+			var ids []int64
+			for id, r := range q.result {
+				ids = append(ids, id)
+				if r != tt.want {
+					t.Errorf("job %d: %s, want %s", id, r, tt.want)
+				}
+			}
+			sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+			if len(ids) != 3 {
+				t.Errorf("finished jobs = %v, want [1 2 3]", ids)
+			}
+		})
+	}
+}
+
+func TestPoolRunShutdownRequeuesBatch(t *testing.T) {
+	q := &recordQueue{result: map[int64]string{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPool(q, func(context.Context, *Job) error {
+		cancel()
+		return context.Canceled
+	}, 1)
+
+	p.run(ctx, &Job{ID: 1, Attempts: DefaultMaxAttempts, MaxAttempts: DefaultMaxAttempts, Batch: []*Job{{ID: 2}}})
+
+	for _, id := range []int64{1, 2} {
+		if q.result[id] != "retry" {
+			t.Errorf("job %d: %q, want retry", id, q.result[id])
+		}
+	}
+}
+
+func TestPoolRunSupersededBatch(t *testing.T) {
+	// ждёт отмены; done — ответ успел уйти раньше, чем пришло новое сообщение
+	waitCancel := func(ctx context.Context, _ *Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tests := []struct {
+		name     string
+		debounce time.Duration
+		newer    bool
+		handle   HandlerFunc
+		want     string
+	}{
+		{"newer job cancels and requeues the batch", time.Second, true, waitCancel, "superseded"},
+		{"finished batch completes despite newer job", time.Second, true, func(context.Context, *Job) error { return nil }, "done"},
+		{"failed batch without newer job retries", time.Second, false, func(context.Context, *Job) error { return errors.New("boom") }, "retry"},
+		{"job without window is not watched", 0, true, func(context.Context, *Job) error { return errors.New("boom") }, "retry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &recordQueue{result: map[int64]string{}, newer: tt.newer}
+			p := NewPool(q, tt.handle, 1)
+			p.watchEvery = time.Millisecond
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				p.run(context.Background(), &Job{
+					ID:          1,
+					Attempts:    1,
+					MaxAttempts: DefaultMaxAttempts,
+					Debounce:    tt.debounce,
+					Batch:       []*Job{{ID: 2}},
+				})
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("run did not finish")
+			}
+
+			for _, id := range []int64{1, 2} {
+				if q.result[id] != tt.want {
+					t.Errorf("job %d: %q, want %s", id, q.result[id], tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestJobRetried(t *testing.T) {
+	tests := []struct {
+		name string
+		job  Job
+		want bool
+	}{
+		{"first attempt", Job{Attempts: 1}, false},
+		{"retry after error", Job{Attempts: 2, LastError: "boom"}, true},
+		{"restart without error", Job{Attempts: 2}, true},
+		{"superseded batch", Job{Attempts: 1, LastError: "superseded by a newer job"}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.job.Retried(); got != tt.want {
+			t.Errorf("%s: Retried = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{0, 5 * time.Second},
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{7, 320 * time.Second},
+		{8, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		// джиттер случайный — проверяем границы на нескольких прогонах
+		for i := 0; i < 50; i++ {
+			got := Backoff(tt.attempt)
+			if lo, hi := tt.base-tt.base/5, tt.base+tt.base/5; got < lo || got > hi {
+				t.Fatalf("Backoff(%d) = %v, want within [%v, %v]", tt.attempt, got, lo, hi)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package logx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+)
+
+// HeaderRequestID — id запроса в заголовках входа и ответа
+const HeaderRequestID = "X-Request-Id"
+
+type attrsKey struct{}
+
+type requestIDKey struct{}
+
+// validRequestID — чужой id принимаем, только если он похож на id, а не на текст
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// With — поля, которые попадут во все записи с этим ctx
+func With(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	prev := attrsFrom(ctx)
+	rec := slog.Record{}
+	rec.Add(args...)
+
+	attrs := make([]slog.Attr, 0, len(prev)+rec.NumAttrs())
+	attrs = append(attrs, prev...)
+	rec.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// WithRequestID — id, который идёт за фрагментом через очередь, пайплайн и outbox
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" || id == RequestID(ctx) {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return With(ctx, "request_id", id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewID — случайный id запроса, 16 hex-символов
+func NewID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Middleware — id запроса из X-Request-Id (прокси, Chatra) или новый; возвращается в ответе
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if !validRequestID.MatchString(id) {
+			id = NewID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
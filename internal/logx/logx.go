@@ -0,0 +1,80 @@
+package logx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Config — уровень и формат логов
+type Config struct {
+	Level  slog.Level
+	Format string // json | text
+}
+
+// ConfigFromEnv — LOG_LEVEL (debug|info|warn|error, по умолчанию info),
+// LOG_FORMAT (json|text, по умолчанию json — для сборщиков логов)
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Level: slog.LevelInfo, Format: "json"}
+
+	if v := strings.TrimSpace(os.Getenv("LOG_LEVEL")); v != "" {
+		if err := cfg.Level.UnmarshalText([]byte(v)); err != nil {
+			return cfg, fmt.Errorf("LOG_LEVEL: %w", err)
+		}
+	}
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv("LOG_FORMAT"))); v {
+	case "":
+	case "json", "text":
+		cfg.Format = v
+	default:
+		return cfg, fmt.Errorf("LOG_FORMAT: unknown format %q", v)
+	}
+	return cfg, nil
+}
+
+// New — логгер с маскировкой персональных данных и полями из контекста
+func New(w io.Writer, cfg Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level, ReplaceAttr: redactAttr}
+
+	var h slog.Handler
+	if cfg.Format == "text" {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(&contextHandler{next: h})
+}
+
+// Setup — логгер по умолчанию в stdout. log.Printf (библиотеки) идут через него же
+// (уровень info, с маскировкой), но без полей из контекста.
+func Setup(cfg Config) {
+	slog.SetDefault(New(os.Stdout, cfg))
+}
+
+// contextHandler — дописывает к записи поля, положенные в ctx через With
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := attrsFrom(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
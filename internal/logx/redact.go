@@ -0,0 +1,94 @@
+package logx
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const masked = "[redacted]"
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// кандидат в телефон; сколько в нём цифр, проверяет isPhone
+	phoneRe = regexp.MustCompile(`\+?\d[\d\s()\-]{8,20}\d`)
+	dateRe  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+	tokenRes = []struct {
+		re   *regexp.Regexp
+		repl string
+	}{
+		// Authorization: Bearer ... / Basic ... / Chatra.Simple public:secret
+		{regexp.MustCompile(`(?i)\b(bearer|basic|chatra\.simple)\s+[^\s"',]+`), "$1 " + masked},
+		// ключи OpenAI / Anthropic
+		{regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}`), masked},
+		// secret=..., "api_key": "...", token: ...
+		{regexp.MustCompile(`(?i)\b(secret|token|password|api[_\-]?key|authorization)("?\s*[:=]\s*"?)[^\s"',&]+`), "$1$2" + masked},
+		// ссылки подтверждения: токен в пути и есть доступ
+		{regexp.MustCompile(`/(approve|reject)/[A-Za-z0-9_\-]{16,}`), "/$1/" + masked},
+	}
+
+	// поля, значение которых не пишется вовсе
+	sensitiveKeys = map[string]bool{
+		"secret":           true,
+		"token":            true,
+		"password":         true,
+		"authorization":    true,
+		"api_key":          true,
+		"apikey":           true,
+		"x-webhook-secret": true,
+	}
+)
+
+// Redact — маскирует email, телефоны и токены в строке
+func Redact(s string) string {
+	for _, t := range tokenRes {
+		s = t.re.ReplaceAllString(s, t.repl)
+	}
+	s = emailRe.ReplaceAllString(s, "[email]")
+	s = phoneRe.ReplaceAllStringFunc(s, func(m string) string {
+		if isPhone(m) {
+			return "[phone]"
+		}
+		return m
+	})
+	return s
+}
+
+// isPhone — 10–15 цифр (E.164), и это не дата
+func isPhone(s string) bool {
+	if dateRe.MatchString(s) {
+		return false
+	}
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n >= 10 && n <= 15
+}
+
+func sensitiveKey(key string) bool {
+	k := strings.ToLower(key)
+	return sensitiveKeys[k] || strings.HasSuffix(k, "_token") || strings.HasSuffix(k, "_secret")
+}
+
+// redactAttr — ReplaceAttr для slog: сообщение, строки и ошибки — через Redact
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKey(a.Key) {
+		return slog.String(a.Key, masked)
+	}
+	if a.Key == "request_id" {
+		return a // свой id, проверен в Middleware
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,74 @@
+package logx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "не работает VPN", "не работает VPN"},
+		{"email", "пишите на ivan.petrov+vpn@example.com.", "пишите на [email]."},
+		{"phone with formatting", "мой номер +7 (999) 123-45-67", "мой номер [phone]"},
+		{"phone digits", "79991234567", "[phone]"},
+		{"short number kept", "код 123-45-67", "код 123-45-67"},
+		{"date kept", "с 2024-05-01 12:30 не работает", "с 2024-05-01 12:30 не работает"},
+		{"bearer", "Bearer eyJhbGciOi.x.y", "Bearer [redacted]"},
+		{"chatra auth", "Chatra.Simple pubkey:seckey", "Chatra.Simple [redacted]"},
+		{"openai key", "key sk-proj-abcdefghijklmnop1234", "key [redacted]"},
+		{"json field", `{"api_key": "xyz123"}`, `{"api_key": "[redacted]"}`},
+		{"query param", "url?secret=s3cr3t&x=1", "url?secret=[redacted]&x=1"},
+		{"approval link", "https://bot/approve/AbCdEfGhIjKlMnOp1234", "https://bot/approve/[redacted]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerRedactsAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, Config{Level: slog.LevelInfo, Format: "json"})
+
+	ctx := With(WithRequestID(context.Background(), "req-1"), "chat_id", "chat")
+	log.InfoContext(ctx, "письмо от a@b.co",
+		"x-webhook-secret", "s3cr3t",
+		"chatra_token", "abc",
+		"text", "звоните 79991234567",
+		"err", errors.New("auth failed: Bearer abc"),
+		"count", 79991234567,
+	)
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+
+	want := map[string]any{
+		"msg":              "письмо от [email]",
+		"x-webhook-secret": masked,
+		"chatra_token":     masked,
+		"text":             "звоните [phone]",
+		"err":              "auth failed: Bearer " + masked,
+		"count":            float64(79991234567), // числа не трогаем
+		"request_id":       "req-1",
+		"chat_id":          "chat",
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Метрики процесса в формате Prometheus; отдаются на /metrics.
+// Метки — только с ограниченным набором значений: без chat_id, client_id и текста.
+var (
+	// WebhookRequests — вебхуки Chatra: event — eventName, result — чем кончилось
+	WebhookRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_webhook_requests_total",
+		Help: "Chatra webhooks by event name and result.",
+	}, []string{"event", "result"})
+
+	// Dedup — отброшенные повторы: webhook_fragment | message_chatra_id | message_hash
+	Dedup = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_dedup_total",
+		Help: "Dropped duplicate webhooks and messages by kind.",
+	}, []string{"kind"})
+
+	// StageDuration — этап пайплайна целиком: вызов модели, починка JSON, кеш
+	StageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bridge_stage_duration_seconds",
+		Help:    "Pipeline stage latency.",
+		Buckets: []float64{0.05, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"stage", "result"})
+
+	// FinalMode — итог прогона: SELF_CONFIDENCE | NEED_OPERATOR | PARSE_ERROR | AI_ERROR
+	FinalMode = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_pipeline_final_mode_total",
+		Help: "Pipeline runs by final mode and outcome.",
+	}, []string{"mode", "outcome"})
+
+	// AIRequests — вызовы провайдеров: result — ok | error | timeout
+	AIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_ai_requests_total",
+		Help: "LLM provider calls by provider, model and result.",
+	}, []string{"provider", "model", "result"})
+
+	AIDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bridge_ai_request_duration_seconds",
+		Help:    "LLM provider call latency.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60, 120},
+	}, []string{"provider"})
+
+	// AITokens — kind: prompt | completion
+	AITokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_ai_tokens_total",
+		Help: "LLM tokens by provider, model and kind.",
+	}, []string{"provider", "model", "kind"})
+
+	AICost = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_ai_cost_usd_total",
+		Help: "LLM spend in USD by provider and model (AI_PRICES).",
+	}, []string{"provider", "model"})
+
+	// AIParse — разбор JSON-ответов: ok | repaired | failed
+	AIParse = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_ai_parse_total",
+		Help: "Structured output parsing by stage and result.",
+	}, []string{"stage", "result"})
+
+	// AICache — кеш ответов модели: hit | miss
+	AICache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_ai_cache_total",
+		Help: "LLM response cache lookups by stage and result.",
+	}, []string{"stage", "result"})
+
+	// ChatraRequests — попытки запросов к API Chatra: result — HTTP-код | error | circuit_open
+	ChatraRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bridge_chatra_requests_total",
+		Help: "Chatra API attempts by method, endpoint and result.",
+	}, []string{"method", "endpoint", "result"})
+
+	ChatraDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bridge_chatra_request_duration_seconds",
+		Help:    "Chatra API attempt latency.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "endpoint"})
+
+	// DBQueryDuration — op: первое слово запроса (select, insert, update, ...)
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bridge_db_query_duration_seconds",
+		Help:    "Postgres query latency by statement kind.",
+		Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"op", "result"})
+)
+
+// Handler — /metrics; горутины, память и GC — стандартные go_* метрики
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
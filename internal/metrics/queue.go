@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DepthFunc — число задач по статусам и возраст самой старой ждущей, секунды
+type DepthFunc func(ctx context.Context) (map[string]int, float64, error)
+
+var (
+	queueJobsDesc = prometheus.NewDesc(
+		"bridge_queue_jobs", "Jobs in a queue by status.", []string{"queue", "status"}, nil,
+	)
+	queueOldestDesc = prometheus.NewDesc(
+		"bridge_queue_oldest_pending_seconds", "Age of the oldest pending job.", []string{"queue"}, nil,
+	)
+)
+
+// queueCollector — глубина очереди читается из БД в момент опроса /metrics
+type queueCollector struct {
+	name  string
+	depth DepthFunc
+}
+
+// RegisterQueue — глубина очереди name (jobs, outbox) в /metrics
+func RegisterQueue(name string, depth DepthFunc) {
+	prometheus.MustRegister(&queueCollector{name: name, depth: depth})
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueJobsDesc
+	ch <- queueOldestDesc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	byStatus, oldest, err := c.depth(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "metrics: queue depth failed", "queue", c.name, "err", err)
+		return
+	}
+	for status, n := range byStatus {
+		ch <- prometheus.MustNewConstMetric(queueJobsDesc, prometheus.GaugeValue, float64(n), c.name, status)
+	}
+	ch <- prometheus.MustNewConstMetric(queueOldestDesc, prometheus.GaugeValue, oldest, c.name)
+}
@@ -0,0 +1,168 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/jobs"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/logx"
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/tracing"
+)
+
+const (
+	DefaultMaxAttempts = 20
+
+	pollInterval  = 2 * time.Second
+	sendTimeout   = 30 * time.Second
+	keepSent      = 72 * time.Hour
+	housekeepTick = time.Minute
+)
+
+// Dispatcher — доставляет outbox; разные клиенты параллельно, для одного клиента — по порядку внутри вида
+type Dispatcher struct {
+	store   Store
+	send    SendFunc
+	workers int
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+func NewDispatcher(store Store, send SendFunc, workers int) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Dispatcher{store: store, send: send, workers: workers}
+}
+
+// Start — всё sending на старте осталось от упавшего процесса (бридж — один инстанс)
+func (d *Dispatcher) Start(ctx context.Context) {
+	ctx, d.cancel = context.WithCancel(ctx)
+
+	if n, err := d.store.RequeueSending(ctx); err != nil {
+		slog.ErrorContext(ctx, "outbox: requeue on start failed", "err", err)
+	} else if n > 0 {
+		slog.InfoContext(ctx, "outbox: resumed deliveries after restart", "count", n)
+	}
+
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.loop(ctx)
+	}
+
+	d.wg.Add(1)
+	go d.housekeep(ctx)
+}
+
+func (d *Dispatcher) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) loop(ctx context.Context) {
+	defer d.wg.Done()
+
+	for {
+		item, err := d.store.Claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "outbox: claim failed", "err", err)
+		}
+
+		if item == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-d.store.Notified():
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		d.run(ctx, item)
+	}
+}
+
+func (d *Dispatcher) housekeep(ctx context.Context) {
+	defer d.wg.Done()
+
+	t := time.NewTicker(housekeepTick)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		if _, err := d.store.PruneSent(ctx, keepSent); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "outbox: prune failed", "err", err)
+		}
+	}
+}
+
+func (d *Dispatcher) run(ctx context.Context, item *Item) {
+	ctx = logx.WithRequestID(ctx, item.RequestID)
+	ctx = logx.With(ctx, "outbox_id", item.ID, "tenant", item.TenantID, "chat_id", item.ChatID, "kind", item.Kind)
+
+	sendCtx, span := tracing.Start(tracing.Extract(ctx, item.TraceParent), "outbox.deliver",
+		attribute.Int64("outbox.id", item.ID),
+		attribute.String("outbox.kind", string(item.Kind)),
+		attribute.Int("outbox.attempt", item.Attempts),
+		attribute.String("tenant.id", item.TenantID),
+		attribute.String("chat.id", item.ChatID),
+	)
+	sendCtx, cancel := context.WithTimeout(sendCtx, sendTimeout)
+	err := d.safeSend(sendCtx, item)
+	cancel()
+	tracing.End(span, err)
+
+	dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if ctx.Err() != nil {
+		if err := d.store.Retry(dbCtx, item.ID, time.Now(), "interrupted by shutdown"); err != nil {
+			slog.ErrorContext(ctx, "outbox: requeue failed", "err", err)
+		}
+		return
+	}
+
+	if err == nil {
+		slog.InfoContext(ctx, "outbox: delivered", "attempt", item.Attempts)
+		if err := d.store.MarkSent(dbCtx, item.ID); err != nil {
+			slog.ErrorContext(ctx, "outbox: mark sent failed", "err", err)
+		}
+		return
+	}
+
+	if item.Attempts >= item.MaxAttempts || errors.Is(err, ErrPermanent) {
+		slog.ErrorContext(ctx, "outbox: delivery dead", "attempts", item.Attempts, "err", err)
+		if err := d.store.Fail(dbCtx, item.ID, err.Error()); err != nil {
+			slog.ErrorContext(ctx, "outbox: fail failed", "err", err)
+		}
+		return
+	}
+
+	delay := jobs.Backoff(item.Attempts)
+	slog.WarnContext(ctx, "outbox: delivery failed, retrying", "attempt", item.Attempts, "delay", delay.String(), "err", err)
+	if err := d.store.Retry(dbCtx, item.ID, time.Now().Add(delay), err.Error()); err != nil {
+		slog.ErrorContext(ctx, "outbox: retry failed", "err", err)
+	}
+}
+
+func (d *Dispatcher) safeSend(ctx context.Context, item *Item) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return d.send(ctx, item)
+}
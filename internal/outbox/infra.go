@@ -0,0 +1,196 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type store struct {
+	db   *sql.DB
+	wake chan struct{}
+}
+
+func NewStore(db *sql.DB) Store {
+	return &store{db: db, wake: make(chan struct{}, 1)}
+}
+
+func (s *store) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *store) Notified() <-chan struct{} {
+	return s.wake
+}
+
+// execer — *sql.DB или *sql.Tx
+type execer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *store) Add(ctx context.Context, item *Item) error {
+	if err := add(ctx, s.db, item); err != nil {
+		return err
+	}
+	s.Notify()
+	return nil
+}
+
+func (s *store) AddTx(ctx context.Context, tx *sql.Tx, item *Item) error {
+	return add(ctx, tx, item)
+}
+
+func add(ctx context.Context, q execer, item *Item) error {
+	if item.MaxAttempts == 0 {
+		item.MaxAttempts = DefaultMaxAttempts
+	}
+	var messageID, replyTo *int64
+	if item.MessageID != 0 {
+		messageID = &item.MessageID
+	}
+	if item.ReplyTo != 0 {
+		replyTo = &item.ReplyTo
+	}
+	return q.QueryRowContext(ctx, `
+		INSERT INTO outbox (tenant_id, chat_id, client_id, message_id, reply_to, kind, text, max_attempts, last_error, request_id, trace_parent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, status, run_at
+	`,
+		item.TenantID,
+		item.ChatID,
+		item.ClientID,
+		messageID,
+		replyTo,
+		string(item.Kind),
+		item.Text,
+		item.MaxAttempts,
+		item.LastError,
+		item.RequestID,
+		item.TraceParent,
+	).Scan(&item.ID, &item.Status, &item.RunAt)
+}
+
+func (s *store) Claim(ctx context.Context) (*Item, error) {
+	var it Item
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE outbox SET
+			status = 'sending',
+			attempts = attempts + 1,
+			locked_at = now(),
+			updated_at = now()
+		WHERE id = (
+			SELECT o.id FROM outbox o
+			WHERE o.status = 'pending' AND o.run_at <= now()
+				-- у клиента есть более ранняя неотправленная запись того же вида:
+				-- сообщения и заметки идут в разные API и друг друга не ждут
+				AND NOT EXISTS (
+					SELECT 1 FROM outbox e
+					WHERE e.tenant_id = o.tenant_id AND e.client_id = o.client_id AND e.kind = o.kind
+						AND e.status IN ('pending', 'sending') AND e.id < o.id
+				)
+			ORDER BY o.run_at, o.id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, tenant_id, chat_id, client_id, COALESCE(message_id, 0), kind, text,
+		          status, attempts, max_attempts, run_at, last_error, request_id, trace_parent
+	`).Scan(
+		&it.ID,
+		&it.TenantID,
+		&it.ChatID,
+		&it.ClientID,
+		&it.MessageID,
+		&it.Kind,
+		&it.Text,
+		&it.Status,
+		&it.Attempts,
+		&it.MaxAttempts,
+		&it.RunAt,
+		&it.LastError,
+		&it.RequestID,
+		&it.TraceParent,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &it, nil
+}
+
+func (s *store) MarkSent(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		WITH o AS (
+			UPDATE outbox
+			SET status = 'sent', sent_at = now(), locked_at = NULL, updated_at = now()
+			WHERE id = $1
+			RETURNING message_id
+		)
+		UPDATE messages m
+		SET delivery_status = 'sent', delivered_at = now(), delivery_error = ''
+		FROM o
+		WHERE m.id = o.message_id
+	`, id)
+	return err
+}
+
+func (s *store) Retry(ctx context.Context, id int64, runAt time.Time, lastErr string) error {
+	_, err := s.db.ExecContext(ctx, `
+		WITH o AS (
+			UPDATE outbox
+			SET status = 'pending', run_at = $2, last_error = $3, locked_at = NULL, updated_at = now()
+			WHERE id = $1
+			RETURNING message_id
+		)
+		UPDATE messages m
+		SET delivery_error = $3
+		FROM o
+		WHERE m.id = o.message_id
+	`, id, runAt, lastErr)
+	return err
+}
+
+func (s *store) Fail(ctx context.Context, id int64, lastErr string) error {
+	_, err := s.db.ExecContext(ctx, `
+		WITH o AS (
+			UPDATE outbox
+			SET status = 'dead', last_error = $2, locked_at = NULL, updated_at = now()
+			WHERE id = $1
+			RETURNING message_id
+		)
+		UPDATE messages m
+		SET delivery_status = 'failed', delivery_error = $2
+		FROM o
+		WHERE m.id = o.message_id
+	`, id, lastErr)
+	return err
+}
+
+func (s *store) RequeueSending(ctx context.Context) (int, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET status = 'pending', locked_at = NULL, updated_at = now()
+		WHERE status = 'sending'
+	`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *store) PruneSent(ctx context.Context, olderThan time.Duration) (int, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM outbox WHERE status = 'sent' AND sent_at < $1
+	`, time.Now().Add(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
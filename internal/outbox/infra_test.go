@@ -0,0 +1,93 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/dbtest"
+)
+
+func addItem(t *testing.T, s Store, clientID string, kind Kind) *Item {
+	t.Helper()
+	it := &Item{TenantID: "t", ChatID: "chat-" + clientID, ClientID: clientID, Kind: kind, Text: "текст"}
+	if err := s.Add(context.Background(), it); err != nil {
+		t.Fatal(err)
+	}
+	return it
+}
+
+func claimID(t *testing.T, s Store) int64 {
+	t.Helper()
+	it, err := s.Claim(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it == nil {
+		return 0
+	}
+	return it.ID
+}
+
+func TestClaimOrderPerClientAndKind(t *testing.T) {
+	s := NewStore(dbtest.Open(t))
+	ctx := context.Background()
+
+	msg1 := addItem(t, s, "c1", KindMessage)
+	note1 := addItem(t, s, "c1", KindNote)
+	msg2 := addItem(t, s, "c1", KindMessage)
+	other := addItem(t, s, "c2", KindMessage)
+
+	steps := []struct {
+		name string
+		want int64
+	}{
+		{"first message of c1", msg1.ID},
+		{"note of c1 does not wait for the message", note1.ID},
+		{"second message waits for the first, c2 goes", other.ID},
+		{"nothing left while c1 message is sending", 0},
+	}
+	for _, st := range steps {
+		if got := claimID(t, s); got != st.want {
+			t.Fatalf("%s: claimed %d, want %d", st.name, got, st.want)
+		}
+	}
+
+	// сообщение ждёт повтора — следующее сообщение его не обгоняет
+	if err := s.Retry(ctx, msg1.ID, time.Now().Add(time.Hour), "503"); err != nil {
+		t.Fatal(err)
+	}
+	if got := claimID(t, s); got != 0 {
+		t.Fatalf("message %d overtook a retrying message", got)
+	}
+
+	if err := s.Retry(ctx, msg1.ID, time.Now(), "503"); err != nil {
+		t.Fatal(err)
+	}
+	if got := claimID(t, s); got != msg1.ID {
+		t.Fatalf("claimed %d, want retried %d", got, msg1.ID)
+	}
+	if err := s.MarkSent(ctx, msg1.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := claimID(t, s); got != msg2.ID {
+		t.Fatalf("claimed %d, want %d", got, msg2.ID)
+	}
+}
+
+func TestClaimDeadDoesNotBlock(t *testing.T) {
+	s := NewStore(dbtest.Open(t))
+
+	first := addItem(t, s, "c1", KindMessage)
+	second := addItem(t, s, "c1", KindMessage)
+
+	if got := claimID(t, s); got != first.ID {
+		t.Fatalf("claimed %d, want %d", got, first.ID)
+	}
+	if err := s.Fail(context.Background(), first.ID, "400"); err != nil {
+		t.Fatal(err)
+	}
+	if got := claimID(t, s); got != second.ID {
+		t.Fatalf("claimed %d, want %d after the first is dead", got, second.ID)
+	}
+}
@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrPermanent — повтор не поможет (4xx от Chatra, нет тенанта)
+var ErrPermanent = errors.New("permanent delivery error")
+
+type Kind string
+
+const (
+	KindMessage Kind = "message" // сообщение клиенту
+	KindNote    Kind = "note"    // заметка оператору
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusSending Status = "sending"
+	StatusSent    Status = "sent"
+	StatusDead    Status = "dead" // попытки исчерпаны
+)
+
+// Item — одна отправка в Chatra
+type Item struct {
+	ID          int64
+	TenantID    string
+	ChatID      string
+	ClientID    string
+	MessageID   int64 // сообщение AI, статус доставки которого обновляется; 0 — заметка
+	ReplyTo     int64 // сообщение клиента, на которое это ответ или заметка; 0 — без привязки
+	Kind        Kind
+	Text        string
+	Status      Status
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LastError   string
+	RequestID   string // id запроса вебхука — для логов диспетчера
+	TraceParent string // W3C traceparent: отправка — в трассе исходного вебхука
+}
+
+// Store — persistence outbox
+type Store interface {
+	Add(ctx context.Context, item *Item) error
+	// AddTx — в транзакции вызывающего, вместе с сохранением сообщения
+	AddTx(ctx context.Context, tx *sql.Tx, item *Item) error
+	// Claim — следующая отправка; nil, nil — нечего слать.
+	// Отправки одному клиенту идут строго по порядку внутри вида: сообщения
+	// по порядку, заметки по порядку; застрявшая заметка не держит ответ.
+	Claim(ctx context.Context) (*Item, error)
+	// MarkSent, Retry, Fail — заодно обновляют delivery_status связанного сообщения
+	MarkSent(ctx context.Context, id int64) error
+	Retry(ctx context.Context, id int64, runAt time.Time, lastErr string) error
+	Fail(ctx context.Context, id int64, lastErr string) error
+
+	// Notify — разбудить диспетчер (после коммита транзакции с AddTx);
+	// Add будит сам
+	Notify()
+	Notified() <-chan struct{}
+
+	// RequeueSending — вернуть в pending зависшие в sending (упавший процесс)
+	RequeueSending(ctx context.Context) (int, error)
+	PruneSent(ctx context.Context, olderThan time.Duration) (int, error)
+}
+
+// SendFunc — реальная отправка; ErrPermanent — в dead без повторов
+type SendFunc func(ctx context.Context, item *Item) error
@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// budgetRecheck — как часто пересчитывать траты; между пересчётами — кеш
+const budgetRecheck = 30 * time.Second
+
+// AlertFunc — сообщение дежурным (лог, вебхук в чат команды)
+type AlertFunc func(ctx context.Context, text string)
+
+// Budget — дневной и месячный лимит трат на модели по всем тенантам (UTC).
+// Превышен — ответы клиентам не уходят, пайплайн пишет только заметки.
+// nil-safe: nil — без лимитов.
+type Budget struct {
+	repo    Repo
+	daily   float64 // USD; 0 — без лимита
+	monthly float64
+	alert   AlertFunc
+
+	mu        sync.Mutex
+	status    BudgetStatus
+	checkedAt time.Time
+	alerted   map[string]bool // период → алерт уже отправлен
+}
+
+// BudgetStatus — траты текущего дня и месяца против лимитов
+type BudgetStatus struct {
+	Daily      float64 `json:"daily_usd"`
+	Monthly    float64 `json:"monthly_usd"`
+	SpentToday float64 `json:"spent_today_usd"`
+	SpentMonth float64 `json:"spent_month_usd"`
+	Exceeded   bool    `json:"exceeded"`
+	Reason     string  `json:"reason,omitempty"`
+}
+
+func NewBudget(repo Repo, daily, monthly float64, alert AlertFunc) *Budget {
+	if daily <= 0 && monthly <= 0 {
+		return nil
+	}
+	if alert == nil {
+		alert = LogAlert
+	}
+	return &Budget{
+		repo:    repo,
+		daily:   daily,
+		monthly: monthly,
+		alert:   alert,
+		alerted: map[string]bool{},
+	}
+}
+
+// Exceeded — лимит исчерпан; ошибка БД лимит не включает, а пишется в лог
+func (b *Budget) Exceeded(ctx context.Context) (bool, string) {
+	if b == nil {
+		return false, ""
+	}
+	st, err := b.Status(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "budget check failed", "err", err)
+	}
+	return st.Exceeded, st.Reason
+}
+
+func (b *Budget) Status(ctx context.Context) (BudgetStatus, error) {
+	if b == nil {
+		return BudgetStatus{}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UTC()
+	if !b.checkedAt.IsZero() && now.Sub(b.checkedAt) < budgetRecheck {
+		return b.status, nil
+	}
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	spentToday, err := b.repo.Spent(ctx, day)
+	if err != nil {
+		return b.status, err
+	}
+	spentMonth, err := b.repo.Spent(ctx, month)
+	if err != nil {
+		return b.status, err
+	}
+
+	st := BudgetStatus{Daily: b.daily, Monthly: b.monthly, SpentToday: spentToday, SpentMonth: spentMonth}
+	period := ""
+	switch {
+	case b.monthly > 0 && spentMonth >= b.monthly:
+		st.Exceeded = true
+		st.Reason = fmt.Sprintf("monthly budget $%.2f exceeded: spent $%.2f", b.monthly, spentMonth)
+		period = "month:" + month.Format("2006-01")
+	case b.daily > 0 && spentToday >= b.daily:
+		st.Exceeded = true
+		st.Reason = fmt.Sprintf("daily budget $%.2f exceeded: spent $%.2f", b.daily, spentToday)
+		period = "day:" + day.Format("2006-01-02")
+	}
+
+	if period != "" && !b.alerted[period] {
+		b.alerted[period] = true
+		// не под локом: вебхук может висеть до таймаута
+		go b.alert(context.WithoutCancel(ctx), "AI budget: "+st.Reason+"; replies switched to note_only")
+	}
+
+	b.status = st
+	b.checkedAt = now
+	return st, nil
+}
+
+// LogAlert — алерт только в лог
+func LogAlert(ctx context.Context, text string) {
+	slog.ErrorContext(ctx, "ALERT", "text", text)
+}
+
+// WebhookAlert — лог + POST {"text": ...} на url (Slack / Mattermost / Telegram-бот)
+func WebhookAlert(url string) AlertFunc {
+	if url == "" {
+		return LogAlert
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return func(ctx context.Context, text string) {
+		LogAlert(ctx, text)
+
+		body, _ := json.Marshal(map[string]string{"text": text})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			slog.ErrorContext(ctx, "alert request failed", "err", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			slog.ErrorContext(ctx, "alert send failed", "err", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			slog.ErrorContext(ctx, "alert send failed", "status", resp.Status)
+		}
+	}
+}
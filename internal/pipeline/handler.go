@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	repo   Repo
+	budget *Budget
+}
+
+func NewHandler(repo Repo, budget *Budget) *Handler {
+	return &Handler{repo: repo, budget: budget}
+}
+
+// Get — GET /admin/runs/{id}: прогон со всеми шагами
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	run, err := h.repo.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "pipeline: get run error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, run)
+}
+
+// ByMessage — GET /admin/messages/{id}/runs
+func (h *Handler) ByMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	runs, err := h.repo.ByMessage(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "pipeline: runs by message error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []Run{}
+	}
+	writeJSON(w, runs)
+}
+
+// Costs — GET /admin/costs?group=chat|day|stage|model|tenant&tenant=&from=2026-01-01&to=2026-02-01
+func (h *Handler) Costs(w http.ResponseWriter, r *http.Request) {
+	q := CostQuery{
+		Group:    r.URL.Query().Get("group"),
+		TenantID: r.URL.Query().Get("tenant"),
+	}
+	if q.Group == "" {
+		q.Group = GroupDay
+	}
+	if !ValidGroup(q.Group) {
+		http.Error(w, "group must be one of chat, day, stage, model, tenant", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if q.From, err = parseDay(r.URL.Query().Get("from")); err != nil {
+		http.Error(w, "invalid from, want YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if q.To, err = parseDay(r.URL.Query().Get("to")); err != nil {
+		http.Error(w, "invalid to, want YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.repo.Costs(r.Context(), q)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "pipeline: costs error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if rows == nil {
+		rows = []CostRow{}
+	}
+	writeJSON(w, rows)
+}
+
+// Budget — GET /admin/budget: траты дня и месяца против лимитов
+func (h *Handler) Budget(w http.ResponseWriter, r *http.Request) {
+	st, err := h.budget.Status(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "pipeline: budget error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, st)
+}
+
+func parseDay(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
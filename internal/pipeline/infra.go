@@ -0,0 +1,329 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+func (r *repo) StartRun(ctx context.Context, run *Run) error {
+	var messageID *int64
+	if run.MessageID != 0 {
+		messageID = &run.MessageID
+	}
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO pipeline_runs (tenant_id, chat_id, message_id, request_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, started_at
+	`, run.TenantID, run.ChatID, messageID, run.RequestID).Scan(&run.ID, &run.StartedAt)
+}
+
+func (r *repo) FinishRun(ctx context.Context, run *Run) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE pipeline_runs
+		SET final_mode = $2, outcome = $3, error = $4,
+		    delivery_mode = $5, delivery_source = $6, finished_at = now()
+		WHERE id = $1
+	`, run.ID, run.FinalMode, run.Outcome, run.Error, run.DeliveryMode, run.DeliverySource)
+	return err
+}
+
+func (r *repo) SaveStep(ctx context.Context, s *Step) error {
+	var parsed *string
+	if len(s.Parsed) > 0 {
+		p := string(s.Parsed)
+		parsed = &p
+	}
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO pipeline_steps (
+			run_id, stage, prompt_version, provider, model, input, raw_output, parsed,
+			latency_ms, prompt_tokens, completion_tokens, total_tokens, cost_usd, cached, error
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id, created_at
+	`,
+		s.RunID,
+		s.Stage,
+		s.PromptVersion,
+		s.Provider,
+		s.Model,
+		string(s.Input),
+		s.RawOutput,
+		parsed,
+		s.LatencyMs,
+		s.PromptTokens,
+		s.CompletionTokens,
+		s.TotalTokens,
+		s.CostUSD,
+		s.Cached,
+		s.Error,
+	).Scan(&s.ID, &s.CreatedAt)
+}
+
+const runColumns = `id, tenant_id, chat_id, COALESCE(message_id, 0), final_mode, outcome, delivery_mode, delivery_source, error, started_at, finished_at, request_id`
+
+func scanRun(row interface{ Scan(...any) error }) (Run, error) {
+	var run Run
+	err := row.Scan(
+		&run.ID,
+		&run.TenantID,
+		&run.ChatID,
+		&run.MessageID,
+		&run.FinalMode,
+		&run.Outcome,
+		&run.DeliveryMode,
+		&run.DeliverySource,
+		&run.Error,
+		&run.StartedAt,
+		&run.FinishedAt,
+		&run.RequestID,
+	)
+	return run, err
+}
+
+func (r *repo) Get(ctx context.Context, id int64) (*Run, error) {
+	run, err := scanRun(r.db.QueryRowContext(ctx, `
+		SELECT `+runColumns+` FROM pipeline_runs WHERE id = $1
+	`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []Run{run}
+	if err := r.attach(ctx, runs); err != nil {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+func (r *repo) ByMessage(ctx context.Context, messageID int64) ([]Run, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+runColumns+` FROM pipeline_runs WHERE message_id = $1 ORDER BY id
+	`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+	return out, rows.Err()
+}
+
+func (r *repo) ByChat(ctx context.Context, tenantID, chatID string, limit int) ([]Run, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+runColumns+`
+		FROM pipeline_runs
+		WHERE tenant_id = $1 AND chat_id = $2
+		ORDER BY id DESC
+		LIMIT $3
+	`, tenantID, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.attach(ctx, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// attach — шаги и разметка для пачки прогонов двумя запросами
+func (r *repo) attach(ctx context.Context, runs []Run) error {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(runs))
+	byID := make(map[int64]*Run, len(runs))
+	for i := range runs {
+		ids[i] = runs[i].ID
+		byID[runs[i].ID] = &runs[i]
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, run_id, stage, prompt_version, provider, model, input, raw_output, parsed,
+		       latency_ms, prompt_tokens, completion_tokens, total_tokens, cost_usd::float8, cached, error, created_at
+		FROM pipeline_steps
+		WHERE run_id = ANY($1)
+		ORDER BY id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Step
+		var input string
+		var parsed sql.NullString
+		if err := rows.Scan(
+			&s.ID,
+			&s.RunID,
+			&s.Stage,
+			&s.PromptVersion,
+			&s.Provider,
+			&s.Model,
+			&input,
+			&s.RawOutput,
+			&parsed,
+			&s.LatencyMs,
+			&s.PromptTokens,
+			&s.CompletionTokens,
+			&s.TotalTokens,
+			&s.CostUSD,
+			&s.Cached,
+			&s.Error,
+			&s.CreatedAt,
+		); err != nil {
+			return err
+		}
+		s.Input = []byte(input)
+		if parsed.Valid {
+			s.Parsed = []byte(parsed.String)
+		}
+		run := byID[s.RunID]
+		run.Steps = append(run.Steps, s)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	fbRows, err := r.db.QueryContext(ctx, `
+		SELECT id, run_id, verdict, comment, author, created_at
+		FROM run_feedback
+		WHERE run_id = ANY($1)
+		ORDER BY id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer fbRows.Close()
+
+	for fbRows.Next() {
+		var fb Feedback
+		var verdict string
+		if err := fbRows.Scan(&fb.ID, &fb.RunID, &verdict, &fb.Comment, &fb.Author, &fb.CreatedAt); err != nil {
+			return err
+		}
+		fb.Verdict = Verdict(verdict)
+		run := byID[fb.RunID]
+		run.Feedback = append(run.Feedback, fb)
+	}
+	return fbRows.Err()
+}
+
+func (r *repo) AddFeedback(ctx context.Context, fb *Feedback) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO run_feedback (run_id, verdict, comment, author)
+		SELECT id, $2, $3, $4 FROM pipeline_runs WHERE id = $1
+		RETURNING id, created_at
+	`, fb.RunID, string(fb.Verdict), fb.Comment, fb.Author).Scan(&fb.ID, &fb.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// costKeys — выражение ключа группировки; только из этого списка, не из запроса
+var costKeys = map[string]string{
+	GroupChat:   `r.tenant_id || '/' || r.chat_id`,
+	GroupDay:    `to_char(s.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')`,
+	GroupStage:  `s.stage`,
+	GroupModel:  `CASE WHEN s.provider = '' THEN s.model ELSE s.provider || ':' || s.model END`,
+	GroupTenant: `r.tenant_id`,
+}
+
+func (r *repo) Costs(ctx context.Context, q CostQuery) ([]CostRow, error) {
+	key, ok := costKeys[q.Group]
+	if !ok {
+		return nil, ErrInvalid
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+key+` AS key,
+		       count(*),
+		       count(DISTINCT s.run_id),
+		       COALESCE(sum(s.prompt_tokens), 0),
+		       COALESCE(sum(s.completion_tokens), 0),
+		       COALESCE(sum(s.total_tokens), 0),
+		       COALESCE(sum(s.cost_usd), 0)::float8
+		FROM pipeline_steps s
+		JOIN pipeline_runs r ON r.id = s.run_id
+		WHERE ($1 = '' OR r.tenant_id = $1)
+		  AND ($2::timestamptz IS NULL OR s.created_at >= $2)
+		  AND ($3::timestamptz IS NULL OR s.created_at < $3)
+		GROUP BY 1
+		ORDER BY 7 DESC, 1
+	`, q.TenantID, nullTime(q.From), nullTime(q.To))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []CostRow
+	for rows.Next() {
+		var c CostRow
+		if err := rows.Scan(
+			&c.Key,
+			&c.Calls,
+			&c.Runs,
+			&c.PromptTokens,
+			&c.CompletionTokens,
+			&c.TotalTokens,
+			&c.CostUSD,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *repo) Spent(ctx context.Context, since time.Time) (float64, error) {
+	var spent float64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(sum(cost_usd), 0)::float8 FROM pipeline_steps WHERE created_at >= $1
+	`, since).Scan(&spent)
+	return spent, err
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
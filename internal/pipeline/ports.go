@@ -0,0 +1,148 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("run not found")
+	ErrInvalid  = errors.New("invalid feedback")
+)
+
+// Исход прогона — что ушло клиенту или оператору
+const (
+	OutcomeSent      = "sent"      // ответ отправлен в чат
+	OutcomeNote      = "note"      // заметка оператору
+	OutcomeApproval  = "approval"  // черновик ждёт подтверждения оператора
+	OutcomeShadow    = "shadow"    // режим shadow: ответ только в трассе
+	OutcomeSkipped   = "skipped"   // ничего не отправлено
+	OutcomeCancelled = "cancelled" // прерван остановкой процесса, задача повторится
+)
+
+// Run — один прогон пайплайна по сообщению клиента
+type Run struct {
+	ID        int64  `json:"id"`
+	TenantID  string `json:"tenant_id"`
+	ChatID    string `json:"chat_id"`
+	MessageID int64  `json:"message_id,omitempty"` // 0 — сообщение не сохранено
+	RequestID string `json:"request_id,omitempty"` // id запроса вебхука, как в логах
+	FinalMode string `json:"final_mode"`
+	Outcome   string `json:"outcome"`
+	// режим доставки и откуда он взят: tenant | segment:<name> | case:<id> | default | budget
+	DeliveryMode   string     `json:"delivery_mode,omitempty"`
+	DeliverySource string     `json:"delivery_source,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	Steps          []Step     `json:"steps,omitempty"`
+	Feedback       []Feedback `json:"feedback,omitempty"`
+}
+
+// Step — один вызов модели внутри прогона
+type Step struct {
+	ID               int64           `json:"id"`
+	RunID            int64           `json:"run_id"`
+	Stage            string          `json:"stage"`
+	PromptVersion    string          `json:"prompt_version"`
+	Provider         string          `json:"provider,omitempty"`
+	Model            string          `json:"model"`
+	Input            json.RawMessage `json:"input"`
+	RawOutput        string          `json:"raw_output"`
+	Parsed           json.RawMessage `json:"parsed,omitempty"` // nil — ответ не разобран
+	LatencyMs        int64           `json:"latency_ms"`
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	TotalTokens      int             `json:"total_tokens"`
+	CostUSD          float64         `json:"cost_usd"`
+	Cached           bool            `json:"cached,omitempty"` // ответ из кеша, модель не вызывалась
+	Error            string          `json:"error,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+// Verdict — оценка прогона человеком
+type Verdict string
+
+const (
+	VerdictCorrect   Verdict = "correct"
+	VerdictIncorrect Verdict = "incorrect"
+)
+
+func (v Verdict) Valid() bool {
+	return v == VerdictCorrect || v == VerdictIncorrect
+}
+
+// Feedback — разметка прогона; становится обучающими данными для кейсов и промптов
+type Feedback struct {
+	ID        int64     `json:"id"`
+	RunID     int64     `json:"run_id"`
+	Verdict   Verdict   `json:"verdict"`
+	Comment   string    `json:"comment,omitempty"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Repo interface {
+	StartRun(ctx context.Context, run *Run) error
+	FinishRun(ctx context.Context, run *Run) error
+	SaveStep(ctx context.Context, step *Step) error
+
+	// Get — прогон со всеми шагами
+	Get(ctx context.Context, id int64) (*Run, error)
+	// ByMessage — прогоны по сообщению клиента, без шагов
+	ByMessage(ctx context.Context, messageID int64) ([]Run, error)
+	// ByChat — последние limit прогонов чата со шагами и разметкой, новые первыми
+	ByChat(ctx context.Context, tenantID, chatID string, limit int) ([]Run, error)
+
+	// AddFeedback — ErrNotFound, если прогона нет
+	AddFeedback(ctx context.Context, fb *Feedback) error
+
+	// Costs — токены и стоимость вызовов, сгруппированные по q.Group
+	Costs(ctx context.Context, q CostQuery) ([]CostRow, error)
+	// Spent — сколько потрачено на модели с момента since, все тенанты
+	Spent(ctx context.Context, since time.Time) (float64, error)
+}
+
+// Группировки сводки по стоимости
+const (
+	GroupChat   = "chat"
+	GroupDay    = "day"
+	GroupStage  = "stage"
+	GroupModel  = "model"
+	GroupTenant = "tenant"
+)
+
+func ValidGroup(g string) bool {
+	switch g {
+	case GroupChat, GroupDay, GroupStage, GroupModel, GroupTenant:
+		return true
+	}
+	return false
+}
+
+// CostQuery — пустые поля не фильтруют
+type CostQuery struct {
+	Group    string
+	TenantID string
+	From, To time.Time // [From, To)
+}
+
+type CostRow struct {
+	Key              string  `json:"key"`
+	Calls            int     `json:"calls"`
+	Runs             int     `json:"runs"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// PromptVersion — короткий хеш текста промпта: правка промпта тенанта даёт новую версию
+func PromptVersion(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/logx"
+)
+
+// Recorder — запись трассы одного прогона.
+// Не открылся прогон (Start) — прогон не запускается. Ошибки записи шагов и итога
+// только логируются: модель уже вызвана и оплачена, повтор заплатил бы ещё раз.
+// Стоимость потерянного шага не попадёт в дневной и месячный бюджет — она остаётся
+// в логе и в bridge_ai_cost_usd_total.
+// nil-Recorder ничего не пишет.
+type Recorder struct {
+	repo Repo
+	run  *Run
+}
+
+// Start — открыть прогон; repo == nil — трассировка выключена.
+// Не открылся — ошибка: шаги без прогона не запишутся, их стоимость
+// пропадёт из бюджета, поэтому платные этапы без трассы не запускаем
+func Start(ctx context.Context, repo Repo, tenantID, chatID string, messageID int64) (*Recorder, error) {
+	if repo == nil {
+		return nil, nil
+	}
+	run := &Run{TenantID: tenantID, ChatID: chatID, MessageID: messageID, RequestID: logx.RequestID(ctx)}
+	if err := repo.StartRun(ctx, run); err != nil {
+		return nil, fmt.Errorf("start run: %w", err)
+	}
+	return &Recorder{repo: repo, run: run}, nil
+}
+
+// Step — записать вызов этапа; parsed == nil — ответ не разобран
+func (r *Recorder) Step(ctx context.Context, s Step, parsed any) {
+	if r == nil {
+		return
+	}
+	s.RunID = r.run.ID
+	if parsed != nil {
+		if b, err := json.Marshal(parsed); err == nil {
+			s.Parsed = b
+		}
+	}
+	if !json.Valid(s.Input) {
+		s.Input, _ = json.Marshal(string(s.Input))
+	}
+	if err := r.repo.SaveStep(ctx, &s); err != nil {
+		slog.ErrorContext(ctx, "save step failed, cost lost from budget",
+			"stage", s.Stage, "model", s.Model, "cost_usd", s.CostUSD, "total_tokens", s.TotalTokens, "err", err)
+	}
+}
+
+// RunID — id прогона; 0 — трасса не пишется
+func (r *Recorder) RunID() int64 {
+	if r == nil {
+		return 0
+	}
+	return r.run.ID
+}
+
+// Delivery — выбранный режим доставки
+func (r *Recorder) Delivery(mode, source string) {
+	if r == nil {
+		return
+	}
+	r.run.DeliveryMode = mode
+	r.run.DeliverySource = source
+}
+
+// Finish — итог прогона
+func (r *Recorder) Finish(ctx context.Context, mode, outcome string, err error) {
+	if r == nil {
+		return
+	}
+	r.run.FinalMode = mode
+	r.run.Outcome = outcome
+	if err != nil {
+		r.run.Error = err.Error()
+	}
+	// ответ мог уже уйти, а ctx прогона — отмениться; итог всё равно пишем
+	if err := r.repo.FinishRun(context.WithoutCancel(ctx), r.run); err != nil {
+		slog.ErrorContext(ctx, "finish run failed", "err", err)
+	}
+}
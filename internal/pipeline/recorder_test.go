@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// stubRepo — StartRun с заданной ошибкой, шаги в памяти
+type stubRepo struct {
+	Repo
+	startErr error
+	steps    []Step
+}
+
+func (r *stubRepo) StartRun(_ context.Context, run *Run) error {
+	if r.startErr != nil {
+		return r.startErr
+	}
+	run.ID = 7
+	return nil
+}
+
+func (r *stubRepo) SaveStep(_ context.Context, s *Step) error {
+	r.steps = append(r.steps, *s)
+	return nil
+}
+
+func TestStart(t *testing.T) {
+	dbDown := errors.New("db is down")
+
+	tests := []struct {
+		name    string
+		repo    Repo
+		wantErr error
+		wantRun int64
+	}{
+		{"tracing off", nil, nil, 0},
+		{"run opened", &stubRepo{}, nil, 7},
+		{"run not opened", &stubRepo{startErr: dbDown}, dbDown, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := Start(context.Background(), tt.repo, "t", "chat", 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := rec.RunID(); got != tt.wantRun {
+				t.Errorf("run id = %d, want %d", got, tt.wantRun)
+			}
+		})
+	}
+}
+
+func TestRecorderStepKeepsCost(t *testing.T) {
+	repo := &stubRepo{}
+	rec, err := Start(context.Background(), repo, "t", "chat", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Step(context.Background(), Step{Stage: "answer_builder", Input: []byte("не json"), CostUSD: 0.01}, nil)
+
+	if len(repo.steps) != 1 {
+		t.Fatalf("steps = %d, want 1", len(repo.steps))
+	}
+	s := repo.steps[0]
+	if s.RunID != 7 || s.CostUSD != 0.01 {
+		t.Errorf("step run %d cost %v, want run 7 cost 0.01", s.RunID, s.CostUSD)
+	}
+	if string(s.Input) != `"не json"` {
+		t.Errorf("input = %s, want JSON string", s.Input)
+	}
+}
@@ -0,0 +1,11 @@
+package pipeline
+
+import "github.com/go-chi/chi/v5"
+
+// RegisterAdminRoutes — пути относительно /admin
+func RegisterAdminRoutes(r chi.Router, h *Handler) {
+	r.Get("/runs/{id}", h.Get)
+	r.Get("/messages/{id}/runs", h.ByMessage)
+	r.Get("/costs", h.Costs)
+	r.Get("/budget", h.Budget)
+}
@@ -0,0 +1,118 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidDelivery = errors.New("invalid delivery policy")
+
+// DeliveryMode — что делать с готовым ответом AI
+type DeliveryMode string
+
+const (
+	DeliveryShadow   DeliveryMode = "shadow"    // только трасса, никому ничего не отправляем
+	DeliveryNoteOnly DeliveryMode = "note_only" // заметка оператору
+	DeliveryApprove  DeliveryMode = "approve"   // оператор подтверждает ответ перед отправкой
+	DeliveryLive     DeliveryMode = "live"      // автоответ клиенту
+)
+
+// DefaultDelivery — режим, если тенант ничего не настроил
+const DefaultDelivery = DeliveryNoteOnly
+
+// caution — чем меньше, тем осторожнее режим
+var caution = map[DeliveryMode]int{
+	DeliveryShadow:   0,
+	DeliveryNoteOnly: 1,
+	DeliveryApprove:  2,
+	DeliveryLive:     3,
+}
+
+func (m DeliveryMode) Valid() bool {
+	_, ok := caution[m]
+	return ok
+}
+
+// SegmentRule — сегмент чатов по полю клиента из вебхука (client info, integration data, chat_id)
+type SegmentRule struct {
+	Name   string       `json:"name"`
+	Field  string       `json:"field"`
+	Values []string     `json:"values"`
+	Mode   DeliveryMode `json:"mode"`
+}
+
+func (s SegmentRule) match(fields map[string]any) bool {
+	v, ok := fields[s.Field]
+	if !ok || v == nil {
+		return false
+	}
+	got := fmt.Sprint(v)
+	for _, want := range s.Values {
+		if strings.EqualFold(got, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryPolicy — режимы доставки тенанта.
+// Приоритет: кейс > сегмент (первый подходящий) > режим тенанта.
+type DeliveryPolicy struct {
+	Default  DeliveryMode            `json:"default,omitempty"`
+	Segments []SegmentRule           `json:"segments,omitempty"`
+	Cases    map[string]DeliveryMode `json:"cases,omitempty"` // case id → режим
+}
+
+func (p DeliveryPolicy) IsZero() bool {
+	return p.Default == "" && len(p.Segments) == 0 && len(p.Cases) == 0
+}
+
+func (p DeliveryPolicy) Validate() error {
+	if p.Default != "" && !p.Default.Valid() {
+		return fmt.Errorf("%w: unknown default mode %q", ErrInvalidDelivery, p.Default)
+	}
+	for i, s := range p.Segments {
+		if s.Field == "" || len(s.Values) == 0 {
+			return fmt.Errorf("%w: segment %d needs field and values", ErrInvalidDelivery, i)
+		}
+		if !s.Mode.Valid() {
+			return fmt.Errorf("%w: segment %d: unknown mode %q", ErrInvalidDelivery, i, s.Mode)
+		}
+	}
+	for id, m := range p.Cases {
+		if !m.Valid() {
+			return fmt.Errorf("%w: case %s: unknown mode %q", ErrInvalidDelivery, id, m)
+		}
+	}
+	return nil
+}
+
+// Resolve — режим для конкретного ответа и откуда он взят (для трассы).
+// Если ответ опирается на несколько кейсов с разными режимами — берётся самый осторожный.
+func (p DeliveryPolicy) Resolve(fields map[string]any, caseIDs []string) (DeliveryMode, string) {
+	var (
+		mode   DeliveryMode
+		source string
+	)
+	for _, id := range caseIDs {
+		m, ok := p.Cases[id]
+		if ok && (mode == "" || caution[m] < caution[mode]) {
+			mode, source = m, "case:"+id
+		}
+	}
+	if mode != "" {
+		return mode, source
+	}
+
+	for _, s := range p.Segments {
+		if s.match(fields) {
+			return s.Mode, "segment:" + s.Name
+		}
+	}
+
+	if p.Default != "" {
+		return p.Default, "tenant"
+	}
+	return DefaultDelivery, "default"
+}
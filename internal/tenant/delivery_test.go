@@ -0,0 +1,80 @@
+package tenant
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeliveryResolve(t *testing.T) {
+	policy := DeliveryPolicy{
+		Default: DeliveryApprove,
+		Segments: []SegmentRule{
+			{Name: "vip", Field: "plan", Values: []string{"VIP"}, Mode: DeliveryNoteOnly},
+			{Name: "trial", Field: "plan", Values: []string{"trial", "free"}, Mode: DeliveryLive},
+			{Name: "beta", Field: "beta", Values: []string{"true"}, Mode: DeliveryShadow},
+		},
+		Cases: map[string]DeliveryMode{
+			"CASE_01": DeliveryLive,
+			"CASE_02": DeliveryShadow,
+		},
+	}
+
+	tests := []struct {
+		name       string
+		policy     DeliveryPolicy
+		fields     map[string]any
+		cases      []string
+		wantMode   DeliveryMode
+		wantSource string
+	}{
+		{"case wins over segment", policy, map[string]any{"plan": "vip"}, []string{"CASE_01"}, DeliveryLive, "case:CASE_01"},
+		{"most cautious case", policy, nil, []string{"CASE_01", "CASE_02"}, DeliveryShadow, "case:CASE_02"},
+		{"unconfigured case falls through", policy, nil, []string{"CASE_09"}, DeliveryApprove, "tenant"},
+		{"segment match is case-insensitive", policy, map[string]any{"plan": "vip"}, nil, DeliveryNoteOnly, "segment:vip"},
+		{"first matching segment", policy, map[string]any{"plan": "free", "beta": true}, nil, DeliveryLive, "segment:trial"},
+		{"non-string field", policy, map[string]any{"beta": true}, nil, DeliveryShadow, "segment:beta"},
+		{"nil field does not match", policy, map[string]any{"plan": nil}, nil, DeliveryApprove, "tenant"},
+		{"tenant default", policy, map[string]any{"plan": "pro"}, nil, DeliveryApprove, "tenant"},
+		{"empty policy", DeliveryPolicy{}, nil, []string{"CASE_01"}, DefaultDelivery, "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, source := tt.policy.Resolve(tt.fields, tt.cases)
+			if mode != tt.wantMode || source != tt.wantSource {
+				t.Errorf("Resolve = %s (%s), want %s (%s)", mode, source, tt.wantMode, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestDeliveryValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  DeliveryPolicy
+		wantErr bool
+	}{
+		{"empty", DeliveryPolicy{}, false},
+		{"full", DeliveryPolicy{
+			Default:  DeliveryLive,
+			Segments: []SegmentRule{{Field: "plan", Values: []string{"vip"}, Mode: DeliveryApprove}},
+			Cases:    map[string]DeliveryMode{"CASE_01": DeliveryShadow},
+		}, false},
+		{"unknown default", DeliveryPolicy{Default: "auto"}, true},
+		{"segment without values", DeliveryPolicy{Segments: []SegmentRule{{Field: "plan", Mode: DeliveryLive}}}, true},
+		{"segment with unknown mode", DeliveryPolicy{Segments: []SegmentRule{{Field: "plan", Values: []string{"x"}, Mode: "auto"}}}, true},
+		{"case with unknown mode", DeliveryPolicy{Cases: map[string]DeliveryMode{"CASE_01": "auto"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidDelivery) {
+				t.Errorf("err = %v, want ErrInvalidDelivery", err)
+			}
+		})
+	}
+}
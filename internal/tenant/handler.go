@@ -0,0 +1,206 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/ai"
+)
+
+type Handler struct {
+	repo     Repo
+	registry *Registry
+	models   ModelChecker
+}
+
+func NewHandler(repo Repo, registry *Registry, models ModelChecker) *Handler {
+	return &Handler{repo: repo, registry: registry, models: models}
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	list, err := h.repo.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "tenant: list error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]Tenant, 0, len(list))
+	for _, t := range list {
+		out = append(out, masked(t))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	t, err := h.repo.Get(r.Context(), chi.URLParam(r, "tenant"))
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "tenant: get error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, masked(*t))
+}
+
+// Put — создать или заменить тенанта; пустые секреты не затирают старые
+func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
+	var t Tenant
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	t.ID = chi.URLParam(r, "tenant")
+
+	cur, err := h.repo.Get(r.Context(), t.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		slog.ErrorContext(r.Context(), "tenant: get error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if cur != nil {
+		if t.ChatraSecretKey == "" || t.ChatraSecretKey == maskedValue {
+			t.ChatraSecretKey = cur.ChatraSecretKey
+		}
+		if t.WebhookSecrets == nil || allMasked(t.WebhookSecrets) {
+			t.WebhookSecrets = cur.WebhookSecrets
+		}
+		if t.Delivery.IsZero() {
+			t.Delivery = cur.Delivery
+		}
+	}
+
+	if err := t.Delivery.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := ai.ValidateStages(t.Models); err != nil {
+		http.Error(w, "models: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.models.CheckModels(t.Models); err != nil {
+		http.Error(w, "models: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if t.ChatraPublicKey == "" || t.ChatraSecretKey == "" {
+		http.Error(w, "chatra_public_key and chatra_secret_key are required", http.StatusBadRequest)
+		return
+	}
+
+	err = CheckSecretsFree(r.Context(), h.repo, &t)
+	if errors.Is(err, ErrSecretInUse) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "tenant: list error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.repo.Upsert(r.Context(), &t); err != nil {
+		slog.ErrorContext(r.Context(), "tenant: upsert error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.registry.Invalidate()
+
+	slog.InfoContext(r.Context(), "tenant: saved", "tenant", t.ID)
+	writeJSON(w, http.StatusOK, masked(t))
+}
+
+// GetDelivery — GET /admin/tenants/{tenant}/delivery
+func (h *Handler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	t, err := h.repo.Get(r.Context(), chi.URLParam(r, "tenant"))
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "tenant: get error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, t.Delivery)
+}
+
+// PutDelivery — PUT /admin/tenants/{tenant}/delivery: смена режимов без рестарта
+func (h *Handler) PutDelivery(w http.ResponseWriter, r *http.Request) {
+	var p DeliveryPolicy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if err := p.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.repo.Get(r.Context(), chi.URLParam(r, "tenant"))
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "tenant: get error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// тенант сохраняется целиком — модели с неизвестным провайдером не пропускаем и здесь
+	if err := h.models.CheckModels(t.Models); err != nil {
+		http.Error(w, "models: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t.Delivery = p
+	if err := h.repo.Upsert(r.Context(), t); err != nil {
+		slog.ErrorContext(r.Context(), "tenant: upsert error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.registry.Invalidate()
+
+	slog.InfoContext(r.Context(), "tenant: delivery saved",
+		"tenant", t.ID, "default", p.Default, "segments", len(p.Segments), "cases", len(p.Cases),
+	)
+	writeJSON(w, http.StatusOK, t.Delivery)
+}
+
+// masked — секреты наружу не отдаём
+func masked(t Tenant) Tenant {
+	t.WebhookSecrets = append([]string{}, t.WebhookSecrets...)
+	if t.ChatraSecretKey != "" {
+		t.ChatraSecretKey = maskedValue
+	}
+	for i := range t.WebhookSecrets {
+		t.WebhookSecrets[i] = maskedValue
+	}
+	return t
+}
+
+const maskedValue = "***"
+
+// allMasked — клиент прислал обратно то, что получил из GET
+func allMasked(secrets []string) bool {
+	for _, s := range secrets {
+		if s != maskedValue {
+			return false
+		}
+	}
+	return len(secrets) > 0
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
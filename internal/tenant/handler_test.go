@@ -0,0 +1,91 @@
+package tenant
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/ai"
+)
+
+// openaiOnly — настроен только провайдер openai
+type openaiOnly struct{}
+
+func (openaiOnly) CheckModels(models map[string]ai.Options) error {
+	for stage, o := range models {
+		if p := ai.ParseRoute(o.Model); p.Model != "" && p.Provider != "openai" {
+			return fmt.Errorf("stage %s: provider %q is not configured", stage, p.Provider)
+		}
+	}
+	return nil
+}
+
+// put — PUT по пути роутера админки
+func put(h *Handler, path, body string) int {
+	router := chi.NewRouter()
+	RegisterAdminRoutes(router, h)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, path, strings.NewReader(body)))
+	return w.Code
+}
+
+func TestHandlerPutSecretConflict(t *testing.T) {
+	repo := &memRepo{byID: map[string]Tenant{
+		"a": {ID: "a", ChatraPublicKey: "pub", ChatraSecretKey: "sec", WebhookSecrets: []string{"shared"}},
+	}}
+	h := NewHandler(repo, NewRegistry(repo, time.Minute), openaiOnly{})
+
+	tests := []struct {
+		name string
+		id   string
+		body string
+		want int
+	}{
+		{"secret of another tenant", "b", `{"chatra_public_key":"pub","chatra_secret_key":"sec","webhook_secrets":["shared"]}`, http.StatusConflict},
+		{"own secret kept", "a", `{"chatra_public_key":"pub2","webhook_secrets":["***"]}`, http.StatusOK},
+		{"fresh secret", "b", `{"chatra_public_key":"pub","chatra_secret_key":"sec","webhook_secrets":["b-only"]}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := put(h, "/"+tt.id, tt.body); code != tt.want {
+				t.Errorf("status %d, want %d", code, tt.want)
+			}
+		})
+	}
+	if got := repo.byID["b"].WebhookSecrets; len(got) != 1 || got[0] != "b-only" {
+		t.Errorf("tenant b secrets = %v, want [b-only]", got)
+	}
+}
+
+func TestHandlerRejectsUnknownProvider(t *testing.T) {
+	repo := &memRepo{byID: map[string]Tenant{
+		"bad": {ID: "bad", ChatraPublicKey: "pub", ChatraSecretKey: "sec", Models: map[string]ai.Options{
+			ai.StageAnswerBuilder: {Model: "gemini:pro"},
+		}},
+	}}
+	h := NewHandler(repo, NewRegistry(repo, time.Minute), openaiOnly{})
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"unknown provider in models", "/a", `{"chatra_public_key":"pub","chatra_secret_key":"sec","models":{"answer_builder":"gemini:pro"}}`, http.StatusBadRequest},
+		{"configured provider", "/a", `{"chatra_public_key":"pub","chatra_secret_key":"sec","models":{"answer_builder":"openai:gpt-5.2"}}`, http.StatusOK},
+		{"plain model", "/b", `{"chatra_public_key":"pub","chatra_secret_key":"sec","models":{"answer_builder":"gpt-5.2"}}`, http.StatusOK},
+		{"delivery of tenant with unknown provider", "/bad/delivery", `{"default":"live"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := put(h, tt.path, tt.body); code != tt.want {
+				t.Errorf("status %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
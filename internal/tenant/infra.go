@@ -0,0 +1,139 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+const tenantColumns = `id, name, chatra_public_key, chatra_secret_key, webhook_secrets, prompts, models, delivery`
+
+func (r *repo) List(ctx context.Context) ([]Tenant, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+tenantColumns+`
+		FROM tenants
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Tenant
+	for rows.Next() {
+		var t Tenant
+		if err := scanTenant(rows, &t); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+
+	return out, rows.Err()
+}
+
+func (r *repo) Get(ctx context.Context, id string) (*Tenant, error) {
+	var t Tenant
+	err := scanTenant(r.db.QueryRowContext(ctx, `
+		SELECT `+tenantColumns+`
+		FROM tenants
+		WHERE id = $1
+	`, id), &t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *repo) Upsert(ctx context.Context, t *Tenant) error {
+	prompts, err := json.Marshal(nonNil(t.Prompts))
+	if err != nil {
+		return err
+	}
+	models, err := json.Marshal(nonNil(t.Models))
+	if err != nil {
+		return err
+	}
+	delivery, err := json.Marshal(t.Delivery)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO tenants (id, name, chatra_public_key, chatra_secret_key, webhook_secrets, prompts, models, delivery)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			chatra_public_key = EXCLUDED.chatra_public_key,
+			chatra_secret_key = EXCLUDED.chatra_secret_key,
+			webhook_secrets = EXCLUDED.webhook_secrets,
+			prompts = EXCLUDED.prompts,
+			models = EXCLUDED.models,
+			delivery = EXCLUDED.delivery,
+			updated_at = now()
+	`,
+		t.ID,
+		t.Name,
+		t.ChatraPublicKey,
+		t.ChatraSecretKey,
+		pq.Array(nonNilSlice(t.WebhookSecrets)),
+		prompts,
+		models,
+		delivery,
+	)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTenant(row scanner, t *Tenant) error {
+	var prompts, models, delivery []byte
+	if err := row.Scan(
+		&t.ID,
+		&t.Name,
+		&t.ChatraPublicKey,
+		&t.ChatraSecretKey,
+		pq.Array(&t.WebhookSecrets),
+		&prompts,
+		&models,
+		&delivery,
+	); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(prompts, &t.Prompts); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(models, &t.Models); err != nil {
+		return err
+	}
+	return json.Unmarshal(delivery, &t.Delivery)
+}
+
+func nonNilSlice(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func nonNil[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return map[string]V{}
+	}
+	return m
+}
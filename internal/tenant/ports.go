@@ -0,0 +1,74 @@
+package tenant
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/Vovarama1992/chatra-ai-bridge/internal/ai"
+)
+
+// DefaultID — тенант, которому принадлежат данные до мультитенантности
+const DefaultID = "default"
+
+var (
+	ErrNotFound = errors.New("tenant not found")
+	// ErrNoCredentials — у тенанта нет публичного или секретного ключа Chatra
+	ErrNoCredentials = errors.New("chatra public and secret keys are required")
+	// ErrSecretInUse — секрет вебхука уже выдан другому тенанту: по секрету тенант не определить
+	ErrSecretInUse = errors.New("webhook secret is used by another tenant")
+)
+
+// Tenant — отдельный продукт / воркспейс Chatra
+type Tenant struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	ChatraPublicKey string            `json:"chatra_public_key"`
+	ChatraSecretKey string            `json:"chatra_secret_key,omitempty"`
+	WebhookSecrets  []string          `json:"webhook_secrets,omitempty"` // все активные, для ротации
+	Prompts         map[string]string `json:"prompts"`                   // stage → системный промпт
+	// Models — stage → модель и параметры вызова; строка — только модель
+	Models   map[string]ai.Options `json:"models"`
+	Delivery DeliveryPolicy        `json:"delivery"` // куда уходят ответы AI
+}
+
+// Prompt — промпт этапа с учётом переопределения тенанта
+func (t *Tenant) Prompt(stage string, def string) string {
+	if p := t.Prompts[stage]; p != "" {
+		return p
+	}
+	return def
+}
+
+// CheckSecret — сравнение за постоянное время со всеми активными секретами
+func (t *Tenant) CheckSecret(secret string) bool {
+	if secret == "" {
+		return false
+	}
+	ok := 0
+	for _, s := range t.WebhookSecrets {
+		if s != "" {
+			ok |= subtle.ConstantTimeCompare([]byte(secret), []byte(s))
+		}
+	}
+	return ok == 1
+}
+
+// AIOptions — параметры вызова этапа; незаданное берётся из настроек этапа в AI
+func (t *Tenant) AIOptions(stage string) ai.Options {
+	o := t.Models[stage]
+	o.Stage = stage
+	return o
+}
+
+// ModelChecker — модели тенанта против настроенных провайдеров AI (ai.Registry)
+type ModelChecker interface {
+	CheckModels(models map[string]ai.Options) error
+}
+
+// Repo — persistence
+type Repo interface {
+	List(ctx context.Context) ([]Tenant, error)
+	Get(ctx context.Context, id string) (*Tenant, error)
+	Upsert(ctx context.Context, t *Tenant) error
+}
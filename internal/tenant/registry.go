@@ -0,0 +1,167 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry — кэш тенантов поверх Repo; правки в БД подхватываются через ttl
+type Registry struct {
+	repo Repo
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	byID     map[string]*Tenant
+	loadedAt time.Time
+}
+
+func NewRegistry(repo Repo, ttl time.Duration) *Registry {
+	return &Registry{repo: repo, ttl: ttl}
+}
+
+func (r *Registry) Get(ctx context.Context, id string) (*Tenant, error) {
+	byID, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := byID[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+// BySecret — тенант по секрету вебхука; проверяются все тенанты.
+// ErrSecretInUse — секрет подходит нескольким тенантам: наугад не выбираем
+func (r *Registry) BySecret(ctx context.Context, secret string) (*Tenant, error) {
+	if secret == "" {
+		return nil, ErrNotFound
+	}
+	byID, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []string
+	for _, t := range byID {
+		if t.CheckSecret(secret) {
+			found = append(found, t.ID)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return byID[found[0]], nil
+	default:
+		sort.Strings(found)
+		return nil, fmt.Errorf("%w: %s", ErrSecretInUse, strings.Join(found, ", "))
+	}
+}
+
+func (r *Registry) List(ctx context.Context) ([]*Tenant, error) {
+	byID, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Tenant, 0, len(byID))
+	for _, t := range byID {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// Invalidate — сбросить кэш после правки
+func (r *Registry) Invalidate() {
+	r.mu.Lock()
+	r.byID = nil
+	r.mu.Unlock()
+}
+
+func (r *Registry) load(ctx context.Context) (map[string]*Tenant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.byID != nil && time.Since(r.loadedAt) < r.ttl {
+		return r.byID, nil
+	}
+
+	list, err := r.repo.List(ctx)
+	if err != nil {
+		// БД недоступна — работаем на старом кэше, если он есть
+		if r.byID != nil {
+			return r.byID, nil
+		}
+		return nil, err
+	}
+
+	byID := make(map[string]*Tenant, len(list))
+	for i := range list {
+		byID[list[i].ID] = &list[i]
+	}
+
+	r.byID = byID
+	r.loadedAt = time.Now()
+	return byID, nil
+}
+
+// EnsureDefault — тенант default из env; непустые креды из env всегда побеждают.
+// ErrNoCredentials — ключей Chatra нет ни в env, ни в БД: без них ни один вызов API не пройдёт
+func EnsureDefault(ctx context.Context, repo Repo, def Tenant) error {
+	def.ID = DefaultID
+
+	cur, err := repo.Get(ctx, DefaultID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if cur != nil {
+		if def.Name == "" {
+			def.Name = cur.Name
+		}
+		if def.ChatraPublicKey == "" {
+			def.ChatraPublicKey = cur.ChatraPublicKey
+		}
+		if def.ChatraSecretKey == "" {
+			def.ChatraSecretKey = cur.ChatraSecretKey
+		}
+		if len(def.WebhookSecrets) == 0 {
+			def.WebhookSecrets = cur.WebhookSecrets
+		}
+		def.Prompts = cur.Prompts
+		def.Models = cur.Models
+		def.Delivery = cur.Delivery
+	}
+
+	if def.ChatraPublicKey == "" || def.ChatraSecretKey == "" {
+		return ErrNoCredentials
+	}
+	if err := CheckSecretsFree(ctx, repo, &def); err != nil {
+		return err
+	}
+	return repo.Upsert(ctx, &def)
+}
+
+// CheckSecretsFree — ErrSecretInUse, если секрет вебхука t уже есть у другого тенанта
+func CheckSecretsFree(ctx context.Context, repo Repo, t *Tenant) error {
+	list, err := repo.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, other := range list {
+		if other.ID == t.ID {
+			continue
+		}
+		for _, s := range t.WebhookSecrets {
+			if other.CheckSecret(s) {
+				return fmt.Errorf("%w: %s", ErrSecretInUse, other.ID)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// memRepo — тенанты в памяти
+type memRepo struct {
+	byID map[string]Tenant
+}
+
+func (r *memRepo) List(context.Context) ([]Tenant, error) {
+	var out []Tenant
+	for _, t := range r.byID {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (r *memRepo) Get(_ context.Context, id string) (*Tenant, error) {
+	t, ok := r.byID[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &t, nil
+}
+
+func (r *memRepo) Upsert(_ context.Context, t *Tenant) error {
+	r.byID[t.ID] = *t
+	return nil
+}
+
+func TestEnsureDefault(t *testing.T) {
+	stored := Tenant{ID: DefaultID, ChatraPublicKey: "pub-db", ChatraSecretKey: "sec-db"}
+
+	tests := []struct {
+		name    string
+		stored  *Tenant
+		env     Tenant
+		wantErr error
+		wantPub string
+		wantSec string
+	}{
+		{
+			name:    "fresh install with keys",
+			env:     Tenant{ChatraPublicKey: "pub", ChatraSecretKey: "sec"},
+			wantPub: "pub",
+			wantSec: "sec",
+		},
+		{
+			name:    "fresh install without public key",
+			env:     Tenant{ChatraSecretKey: "sec"},
+			wantErr: ErrNoCredentials,
+		},
+		{
+			name:    "upgrade without env keeps stored keys",
+			stored:  &stored,
+			wantPub: "pub-db",
+			wantSec: "sec-db",
+		},
+		{
+			name:    "env overrides stored keys",
+			stored:  &stored,
+			env:     Tenant{ChatraPublicKey: "pub", ChatraSecretKey: "sec"},
+			wantPub: "pub",
+			wantSec: "sec",
+		},
+		{
+			name:    "stored tenant without public key",
+			stored:  &Tenant{ID: DefaultID, ChatraSecretKey: "sec-db"},
+			wantErr: ErrNoCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memRepo{byID: map[string]Tenant{}}
+			if tt.stored != nil {
+				repo.byID[DefaultID] = *tt.stored
+			}
+
+			err := EnsureDefault(context.Background(), repo, tt.env)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			got := repo.byID[DefaultID]
+			if got.ChatraPublicKey != tt.wantPub || got.ChatraSecretKey != tt.wantSec {
+				t.Errorf("keys = %q/%q, want %q/%q", got.ChatraPublicKey, got.ChatraSecretKey, tt.wantPub, tt.wantSec)
+			}
+		})
+	}
+}
+
+func TestWebhookSecretsUnique(t *testing.T) {
+	repo := &memRepo{byID: map[string]Tenant{
+		"a": {ID: "a", ChatraPublicKey: "pub", ChatraSecretKey: "sec", WebhookSecrets: []string{"s-a", "shared"}},
+	}}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		tenant  Tenant
+		wantErr error
+	}{
+		{"own secrets on update", Tenant{ID: "a", WebhookSecrets: []string{"s-a"}}, nil},
+		{"fresh secret", Tenant{ID: "b", WebhookSecrets: []string{"s-b"}}, nil},
+		{"secret of another tenant", Tenant{ID: "b", WebhookSecrets: []string{"s-b", "shared"}}, ErrSecretInUse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckSecretsFree(ctx, repo, &tt.tenant); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("default tenant with taken secret", func(t *testing.T) {
+		err := EnsureDefault(ctx, repo, Tenant{ChatraPublicKey: "pub", ChatraSecretKey: "sec", WebhookSecrets: []string{"shared"}})
+		if !errors.Is(err, ErrSecretInUse) {
+			t.Errorf("err = %v, want ErrSecretInUse", err)
+		}
+		if _, ok := repo.byID[DefaultID]; ok {
+			t.Error("default tenant saved with a taken secret")
+		}
+	})
+}
+
+func TestBySecretAmbiguous(t *testing.T) {
+	repo := &memRepo{byID: map[string]Tenant{
+		"a": {ID: "a", WebhookSecrets: []string{"s-a", "shared"}},
+		"b": {ID: "b", WebhookSecrets: []string{"shared"}},
+	}}
+	r := NewRegistry(repo, time.Minute)
+	ctx := context.Background()
+
+	tests := []struct {
+		secret  string
+		wantID  string
+		wantErr error
+	}{
+		{"s-a", "a", nil},
+		{"unknown", "", ErrNotFound},
+		{"shared", "", ErrSecretInUse},
+	}
+	for _, tt := range tests {
+		got, err := r.BySecret(ctx, tt.secret)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.secret, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr == nil && got.ID != tt.wantID {
+			t.Errorf("%s: tenant %q, want %q", tt.secret, got.ID, tt.wantID)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package tenant
+
+import "github.com/go-chi/chi/v5"
+
+// RegisterAdminRoutes — пути относительно /admin/tenants
+func RegisterAdminRoutes(r chi.Router, h *Handler) {
+	r.Get("/", h.List)
+	r.Get("/{tenant}", h.Get)
+	r.Put("/{tenant}", h.Put)
+	r.Get("/{tenant}/delivery", h.GetDelivery)
+	r.Put("/{tenant}/delivery", h.PutDelivery)
+}
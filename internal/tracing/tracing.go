@@ -0,0 +1,138 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ServiceName = "chatra-ai-bridge"
+	tracerName  = "github.com/Vovarama1992/chatra-ai-bridge"
+)
+
+// Config — куда отправлять спаны
+type Config struct {
+	Exporter     string  // none | otlp | stdout
+	OTLPEndpoint string  // http://otel-collector:4318; "" — OTEL_EXPORTER_OTLP_ENDPOINT или localhost
+	SampleRatio  float64 // доля трасс 0..1
+}
+
+// ConfigFromEnv — TRACING_EXPORTER (none по умолчанию), TRACING_OTLP_ENDPOINT,
+// TRACING_SAMPLE_RATIO (1 по умолчанию)
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Exporter:     strings.ToLower(strings.TrimSpace(os.Getenv("TRACING_EXPORTER"))),
+		OTLPEndpoint: strings.TrimSpace(os.Getenv("TRACING_OTLP_ENDPOINT")),
+		SampleRatio:  1,
+	}
+	if cfg.Exporter == "" {
+		cfg.Exporter = "none"
+	}
+	switch cfg.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		return cfg, fmt.Errorf("TRACING_EXPORTER: unknown exporter %q", cfg.Exporter)
+	}
+	if v := strings.TrimSpace(os.Getenv("TRACING_SAMPLE_RATIO")); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 || r > 1 {
+			return cfg, fmt.Errorf("TRACING_SAMPLE_RATIO: want 0..1, got %q", v)
+		}
+		cfg.SampleRatio = r
+	}
+	return cfg, nil
+}
+
+// Setup — глобальный TracerProvider и W3C propagator. Exporter none — спаны
+// не создаются вовсе (noop), shutdown ничего не делает.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Exporter {
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Start — дочерний спан от спана в ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End — закрыть спан; ошибка — в статус и событие
+func End(span trace.Span, err error, attrs ...attribute.KeyValue) {
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject — контекст трассы строкой (traceparent) для записи в очередь или outbox;
+// "" — трассы нет
+func Inject(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// Extract — продолжить трассу, сохранённую через Inject
+func Extract(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
+
+// TraceID — id трассы для логов; "" — спан не пишется
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS cases (
+  id TEXT PRIMARY KEY, -- CASE_01
+  title TEXT NOT NULL,
+  triggers TEXT NOT NULL DEFAULT '',
+  steps TEXT NOT NULL DEFAULT '',
+  platforms TEXT[] NOT NULL DEFAULT '{}', -- пусто = любая платформа
+  priority INT NOT NULL DEFAULT 0,
+  refs TEXT[] NOT NULL DEFAULT '{}', -- ссылки вида «→ CASE_25»
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cases_priority ON cases(priority);
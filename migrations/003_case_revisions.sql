@@ -0,0 +1,38 @@
+ALTER TABLE cases ADD COLUMN IF NOT EXISTS revision BIGINT NOT NULL DEFAULT 0;
+
+-- неизменяемая история правок кейсов
+CREATE TABLE IF NOT EXISTS case_revisions (
+  id BIGSERIAL PRIMARY KEY,
+  case_id TEXT NOT NULL,
+  title TEXT NOT NULL,
+  triggers TEXT NOT NULL DEFAULT '',
+  steps TEXT NOT NULL DEFAULT '',
+  platforms TEXT[] NOT NULL DEFAULT '{}',
+  priority INT NOT NULL DEFAULT 0,
+  refs TEXT[] NOT NULL DEFAULT '{}',
+  deleted BOOLEAN NOT NULL DEFAULT false,
+  author TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_case_revisions_case_id ON case_revisions(case_id, id);
+
+-- кейсы, импортированные до появления ревизий
+WITH ins AS (
+  INSERT INTO case_revisions (case_id, title, triggers, steps, platforms, priority, refs, author)
+  SELECT id, title, triggers, steps, platforms, priority, refs, 'import'
+  FROM cases
+  WHERE revision = 0
+  RETURNING id, case_id
+)
+UPDATE cases c SET revision = ins.id FROM ins WHERE c.id = ins.case_id;
+
+-- на каких ревизиях кейсов отвечал пайплайн
+CREATE TABLE IF NOT EXISTS message_case_revisions (
+  message_id BIGINT NOT NULL REFERENCES messages(id),
+  case_id TEXT NOT NULL,
+  revision_id BIGINT NOT NULL REFERENCES case_revisions(id),
+  PRIMARY KEY (message_id, case_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_case_revisions_revision ON message_case_revisions(revision_id);
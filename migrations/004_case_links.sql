@@ -0,0 +1,38 @@
+-- структурные связи между кейсами вместо свободных refs
+ALTER TABLE cases ADD COLUMN IF NOT EXISTS prerequisites TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE cases ADD COLUMN IF NOT EXISTS next TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE case_revisions ADD COLUMN IF NOT EXISTS prerequisites TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE case_revisions ADD COLUMN IF NOT EXISTS next TEXT[] NOT NULL DEFAULT '{}';
+
+-- перенос refs: ссылка на приоритетный кейс (CASE_25, CASE_26) — предпосылка, остальные — next
+DO $$
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM information_schema.columns
+    WHERE table_name = 'cases' AND column_name = 'refs'
+  ) THEN
+    UPDATE cases c SET
+      prerequisites = ARRAY(
+        SELECT r.id FROM unnest(c.refs) WITH ORDINALITY AS r(id, n)
+        JOIN cases p ON p.id = r.id
+        WHERE p.priority > 0
+        ORDER BY r.n
+      ),
+      next = ARRAY(
+        SELECT r.id FROM unnest(c.refs) WITH ORDINALITY AS r(id, n)
+        JOIN cases p ON p.id = r.id
+        WHERE p.priority = 0
+        ORDER BY r.n
+      );
+
+    UPDATE case_revisions cr SET
+      prerequisites = c.prerequisites,
+      next = c.next
+    FROM cases c
+    WHERE cr.id = c.revision;
+
+    ALTER TABLE cases DROP COLUMN refs;
+    ALTER TABLE case_revisions DROP COLUMN refs;
+  END IF;
+END $$;
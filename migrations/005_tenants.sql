@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS tenants (
+  id TEXT PRIMARY KEY, -- slug, используется в пути вебхука
+  name TEXT NOT NULL DEFAULT '',
+  chatra_public_key TEXT NOT NULL,
+  chatra_secret_key TEXT NOT NULL,
+  webhook_secret TEXT NOT NULL DEFAULT '',
+  prompts JSONB NOT NULL DEFAULT '{}', -- stage → системный промпт
+  models JSONB NOT NULL DEFAULT '{}',  -- stage → модель
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- всё, что было до тенантов, принадлежит тенанту default
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE messages ALTER COLUMN tenant_id DROP DEFAULT;
+CREATE INDEX IF NOT EXISTS idx_messages_tenant_chat ON messages(tenant_id, chat_id);
+
+ALTER TABLE cases ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE cases ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE cases DROP CONSTRAINT IF EXISTS cases_pkey;
+ALTER TABLE cases ADD PRIMARY KEY (tenant_id, id);
+
+ALTER TABLE case_revisions ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE case_revisions ALTER COLUMN tenant_id DROP DEFAULT;
+DROP INDEX IF EXISTS idx_case_revisions_case_id;
+CREATE INDEX IF NOT EXISTS idx_case_revisions_tenant_case ON case_revisions(tenant_id, case_id, id);
@@ -0,0 +1,15 @@
+-- несколько активных секретов на тенанта — для ротации
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS webhook_secrets TEXT[] NOT NULL DEFAULT '{}';
+
+DO $$
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM information_schema.columns
+    WHERE table_name = 'tenants' AND column_name = 'webhook_secret'
+  ) THEN
+    UPDATE tenants SET webhook_secrets = ARRAY[webhook_secret]
+    WHERE webhook_secret <> '' AND webhook_secrets = '{}';
+
+    ALTER TABLE tenants DROP COLUMN webhook_secret;
+  END IF;
+END $$;
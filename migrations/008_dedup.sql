@@ -0,0 +1,6 @@
+-- идемпотентность: повторная доставка chatFragment не создаёт дублей
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS dedup_key TEXT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_messages_dedup_key ON messages(tenant_id, dedup_key) WHERE dedup_key IS NOT NULL;
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS dedup_key TEXT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_jobs_dedup_key ON jobs(tenant_id, dedup_key) WHERE dedup_key IS NOT NULL;
@@ -0,0 +1,2 @@
+-- поиск незавершённых задач того же чата при захвате
+CREATE INDEX IF NOT EXISTS idx_jobs_chat_active ON jobs(tenant_id, chat_id, id) WHERE status IN ('pending', 'running');
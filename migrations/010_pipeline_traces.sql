@@ -0,0 +1,34 @@
+-- трасса AI-пайплайна: один прогон на сообщение клиента, шаг на каждый этап
+CREATE TABLE IF NOT EXISTS pipeline_runs (
+  id BIGSERIAL PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  chat_id TEXT NOT NULL,
+  message_id BIGINT NULL REFERENCES messages(id) ON DELETE SET NULL,
+  final_mode TEXT NOT NULL DEFAULT '',
+  outcome TEXT NOT NULL DEFAULT '', -- sent | note | skipped
+  error TEXT NOT NULL DEFAULT '',
+  started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  finished_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pipeline_runs_message ON pipeline_runs(message_id);
+CREATE INDEX IF NOT EXISTS idx_pipeline_runs_chat ON pipeline_runs(tenant_id, chat_id, started_at);
+
+CREATE TABLE IF NOT EXISTS pipeline_steps (
+  id BIGSERIAL PRIMARY KEY,
+  run_id BIGINT NOT NULL REFERENCES pipeline_runs(id) ON DELETE CASCADE,
+  stage TEXT NOT NULL,
+  prompt_version TEXT NOT NULL,
+  model TEXT NOT NULL DEFAULT '',
+  input JSONB NOT NULL,
+  raw_output TEXT NOT NULL DEFAULT '',
+  parsed JSONB NULL,
+  latency_ms INT NOT NULL DEFAULT 0,
+  prompt_tokens INT NOT NULL DEFAULT 0,
+  completion_tokens INT NOT NULL DEFAULT 0,
+  total_tokens INT NOT NULL DEFAULT 0,
+  error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pipeline_steps_run ON pipeline_steps(run_id, id);
@@ -0,0 +1,12 @@
+-- разметка прогонов старшими операторами: верно / неверно + комментарий
+CREATE TABLE IF NOT EXISTS run_feedback (
+  id BIGSERIAL PRIMARY KEY,
+  run_id BIGINT NOT NULL REFERENCES pipeline_runs(id) ON DELETE CASCADE,
+  verdict TEXT NOT NULL, -- correct | incorrect
+  comment TEXT NOT NULL DEFAULT '',
+  author TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_feedback_run ON run_feedback(run_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_run_feedback_verdict ON run_feedback(verdict, created_at);
@@ -0,0 +1,5 @@
+-- режимы доставки ответов AI: shadow | note_only | approve | live
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS delivery JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE pipeline_runs ADD COLUMN IF NOT EXISTS delivery_mode TEXT NOT NULL DEFAULT '';
+ALTER TABLE pipeline_runs ADD COLUMN IF NOT EXISTS delivery_source TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,22 @@
+-- черновики ответов AI, ждущие решения оператора (режим доставки approve)
+CREATE TABLE IF NOT EXISTS drafts (
+  id BIGSERIAL PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  chat_id TEXT NOT NULL,
+  client_id TEXT NOT NULL,
+  message_id BIGINT NULL REFERENCES messages(id) ON DELETE SET NULL, -- вопрос клиента
+  run_id BIGINT NULL REFERENCES pipeline_runs(id) ON DELETE SET NULL,
+  token_hash TEXT NOT NULL UNIQUE, -- sha256 токена; сам токен только в ссылке
+  text TEXT NOT NULL,             -- черновик как его написал AI
+  final_text TEXT NULL,           -- что ушло клиенту (после правки оператора)
+  edit_distance INT NULL,         -- Левенштейн между text и final_text
+  status TEXT NOT NULL DEFAULT 'pending', -- pending | approved | rejected
+  decided_by TEXT NOT NULL DEFAULT '',
+  decided_at TIMESTAMPTZ NULL,
+  sent_message_id BIGINT NULL REFERENCES messages(id) ON DELETE SET NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_drafts_chat ON drafts(tenant_id, chat_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_drafts_pending ON drafts(expires_at) WHERE status = 'pending';
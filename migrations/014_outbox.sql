@@ -0,0 +1,21 @@
+-- исходящие в Chatra, которые не ушли с первого раза (Chatra лежит, 5xx, 429)
+CREATE TABLE IF NOT EXISTS outbox (
+  id BIGSERIAL PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  client_id TEXT NOT NULL,
+  kind TEXT NOT NULL, -- message | note
+  text TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending', -- pending | sending | sent | dead
+  attempts INT NOT NULL DEFAULT 0,
+  max_attempts INT NOT NULL DEFAULT 20,
+  run_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  locked_at TIMESTAMPTZ NULL,
+  last_error TEXT NOT NULL DEFAULT '',
+  sent_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(run_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_client_active ON outbox(tenant_id, client_id, id) WHERE status IN ('pending', 'sending');
+CREATE INDEX IF NOT EXISTS idx_outbox_status ON outbox(status);
@@ -0,0 +1,8 @@
+-- outbox пишется в одной транзакции с сообщением AI; статус доставки виден на самом сообщении
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS chat_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS message_id BIGINT NULL REFERENCES messages(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_message ON outbox(message_id) WHERE message_id IS NOT NULL;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivery_status TEXT NULL; -- pending | sent | failed; NULL — не исходящее
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMPTZ NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivery_error TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- стоимость вызовов модели: считается по таблице цен AI_PRICES в момент вызова
+ALTER TABLE pipeline_steps ADD COLUMN IF NOT EXISTS provider TEXT NOT NULL DEFAULT '';
+ALTER TABLE pipeline_steps ADD COLUMN IF NOT EXISTS cost_usd NUMERIC(14, 6) NOT NULL DEFAULT 0;
+
+-- сводки по дням и бюджет: выборки по времени без прохода по всей трассе
+CREATE INDEX IF NOT EXISTS idx_pipeline_steps_created ON pipeline_steps(created_at);
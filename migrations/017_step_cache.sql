@@ -0,0 +1,2 @@
+-- шаг обслужен кешем ответов: модель не вызывалась, токенов и стоимости нет
+ALTER TABLE pipeline_steps ADD COLUMN IF NOT EXISTS cached BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,4 @@
+-- id запроса вебхука: по нему в логах находится весь путь фрагмента
+-- (очередь, прогон пайплайна, отправка из outbox)
+ALTER TABLE pipeline_runs ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- W3C traceparent: отправка из outbox попадает в трассу вебхука, породившего ответ
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS trace_parent TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- на какое сообщение клиента ответ AI или заметка: повтор задачи не отвечает второй раз
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to BIGINT NULL REFERENCES messages(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_reply_to ON messages(reply_to) WHERE reply_to IS NOT NULL;
+
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS reply_to BIGINT NULL REFERENCES messages(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_reply_to ON outbox(reply_to) WHERE reply_to IS NOT NULL;
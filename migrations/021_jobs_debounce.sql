@@ -0,0 +1,2 @@
+-- окно тишины в очереди: задачи чата копятся до тишины клиента и выполняются одной пачкой
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS debounce_ms BIGINT NOT NULL DEFAULT 0;
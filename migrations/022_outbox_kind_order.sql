@@ -0,0 +1,3 @@
+-- порядок отправки — по клиенту и виду: заметка не ждёт сообщение и наоборот
+DROP INDEX IF EXISTS idx_outbox_client_active;
+CREATE INDEX IF NOT EXISTS idx_outbox_client_kind_active ON outbox(tenant_id, client_id, kind, id) WHERE status IN ('pending', 'sending');
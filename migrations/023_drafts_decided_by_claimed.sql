@@ -0,0 +1,10 @@
+-- имя оператора приходит из формы по ссылке и ничем не подтверждено: хранится как заявленное
+DO $$
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM information_schema.columns
+    WHERE table_schema = current_schema() AND table_name = 'drafts' AND column_name = 'decided_by'
+  ) THEN
+    ALTER TABLE drafts RENAME COLUMN decided_by TO decided_by_claimed;
+  END IF;
+END $$;